@@ -1,37 +1,256 @@
 package binpack
 
+import (
+	"fmt"
+	"slices"
+)
+
 type Packable interface {
 	Size() uint64
+	Owner() string
+}
+
+// Constraints limit what can be put into a single bin.
+type Constraints struct {
+	// Capacity is the maximum total size of items in a bin.
+	Capacity uint64
+	// MaxItems is the maximum number of items in a bin, 0 means unlimited.
+	MaxItems int
+	// MaxPerOwner is the maximum number of items of the same owner in a bin, 0 means unlimited.
+	MaxPerOwner int
+}
+
+// Strategy packs items into bins satisfying the constraints.
+// Items that don't fit into an empty bin are returned as the remainder.
+type Strategy[T Packable] interface {
+	Pack(items []T, c Constraints) (bins [][]T, remainder []T)
+}
+
+// StrategyFunc is an adapter to use ordinary functions as a Strategy.
+type StrategyFunc[T Packable] func(items []T, c Constraints) (bins [][]T, remainder []T)
+
+func (f StrategyFunc[T]) Pack(items []T, c Constraints) (bins [][]T, remainder []T) {
+	return f(items, c)
+}
+
+const (
+	FirstFitName           = "first-fit"
+	FirstFitDecreasingName = "first-fit-decreasing"
+	BestFitName            = "best-fit"
+	BestFitDecreasingName  = "best-fit-decreasing"
+	WorstFitName           = "worst-fit"
+	NextFitName            = "next-fit"
+)
+
+// Strategies returns names of all available strategies.
+func Strategies() []string {
+	return []string{
+		FirstFitName,
+		FirstFitDecreasingName,
+		BestFitName,
+		BestFitDecreasingName,
+		WorstFitName,
+		NextFitName,
+	}
+}
+
+// New returns the strategy by its name.
+func New[T Packable](name string) (Strategy[T], error) {
+	switch name {
+	case FirstFitName:
+		return StrategyFunc[T](FirstFit[T]), nil
+	case FirstFitDecreasingName:
+		return StrategyFunc[T](FirstFitDecreasing[T]), nil
+	case BestFitName:
+		return StrategyFunc[T](BestFit[T]), nil
+	case BestFitDecreasingName:
+		return StrategyFunc[T](BestFitDecreasing[T]), nil
+	case WorstFitName:
+		return StrategyFunc[T](WorstFit[T]), nil
+	case NextFitName:
+		return StrategyFunc[T](NextFit[T]), nil
+	default:
+		return nil, fmt.Errorf("unknown bin packing strategy: %s", name)
+	}
+}
+
+// FirstFit puts each item into the first opened bin it fits into.
+func FirstFit[T Packable](items []T, c Constraints) (bins [][]T, remainder []T) {
+	return pack(items, c, func(p *packer[T], item T) int {
+		return p.tree.first(int64(item.Size()), p.ownerFits(item))
+	})
+}
+
+// FirstFitDecreasing packs items with FirstFit in order of decreasing size.
+func FirstFitDecreasing[T Packable](items []T, c Constraints) (bins [][]T, remainder []T) {
+	return FirstFit(decreasing(items), c)
 }
 
-func FirstFit[T Packable](items []T, cap uint64) (bins [][]T, remainder []T) {
-	binSlice := []uint64{cap}
-
-	for i := 0; i < len(items); i++ {
-		settled := false
-		j := 0
-
-		for !settled && j < len(binSlice) {
-			if items[i].Size() <= binSlice[j] {
-				binSlice[j] -= items[i].Size()
-				if len(bins) < j+1 {
-					bins = append(bins, []T{items[i]})
-				} else {
-					bins[j] = append(bins[j], items[i])
-				}
-				settled = true
-			} else {
-				j++
+// BestFit puts each item into the fullest bin it fits into.
+func BestFit[T Packable](items []T, c Constraints) (bins [][]T, remainder []T) {
+	return pack(items, c, func(p *packer[T], item T) int {
+		best := -1
+
+		for j, f := range p.free {
+			if p.fits(j, item) && (best == -1 || f < p.free[best]) {
+				best = j
 			}
 		}
 
-		if !settled && items[i].Size() <= cap {
-			binSlice = append(binSlice, cap-items[i].Size())
-			bins = append(bins, []T{items[i]})
-		} else if !settled {
-			remainder = append(remainder, items[i])
+		return best
+	})
+}
+
+// BestFitDecreasing packs items with BestFit in order of decreasing size.
+func BestFitDecreasing[T Packable](items []T, c Constraints) (bins [][]T, remainder []T) {
+	return BestFit(decreasing(items), c)
+}
+
+// WorstFit puts each item into the emptiest bin it fits into.
+func WorstFit[T Packable](items []T, c Constraints) (bins [][]T, remainder []T) {
+	return pack(items, c, func(p *packer[T], item T) int {
+		return p.tree.emptiest(int64(item.Size()), p.ownerFits(item))
+	})
+}
+
+// NextFit keeps a single bin open and starts a new one when the item doesn't fit into it.
+func NextFit[T Packable](items []T, c Constraints) (bins [][]T, remainder []T) {
+	return pack(items, c, func(p *packer[T], item T) int {
+		if last := len(p.free) - 1; last >= 0 && p.fits(last, item) {
+			return last
 		}
+
+		return -1
+	})
+}
+
+// packer holds the state of opened bins.
+// Bins that reached the item limit are closed in the tree, so the tree only has to check the owner limit.
+type packer[T Packable] struct {
+	c      Constraints
+	bins   [][]T
+	free   []uint64
+	owners []map[string]int
+	tree   *freeTree
+}
+
+func (p *packer[T]) fits(j int, item T) bool {
+	if item.Size() > p.free[j] {
+		return false
+	}
+
+	if p.c.MaxItems > 0 && len(p.bins[j]) >= p.c.MaxItems {
+		return false
+	}
+
+	return p.c.MaxPerOwner <= 0 || p.owners[j][item.Owner()] < p.c.MaxPerOwner
+}
+
+// ownerFits returns a predicate over bins for the owner limit of the item, nil if there is no limit.
+func (p *packer[T]) ownerFits(item T) func(j int) bool {
+	if p.c.MaxPerOwner <= 0 {
+		return nil
+	}
+
+	return func(j int) bool {
+		return p.owners[j][item.Owner()] < p.c.MaxPerOwner
+	}
+}
+
+// put places the item into bin j or into a new bin if j is -1.
+func (p *packer[T]) put(j int, item T) {
+	if j < 0 {
+		j = p.tree.open(int64(p.c.Capacity))
+		p.bins = append(p.bins, nil)
+		p.free = append(p.free, p.c.Capacity)
+		p.owners = append(p.owners, nil)
+	}
+
+	p.bins[j] = append(p.bins[j], item)
+	p.free[j] -= item.Size()
+
+	if p.c.MaxPerOwner > 0 {
+		if p.owners[j] == nil {
+			p.owners[j] = map[string]int{}
+		}
+
+		p.owners[j][item.Owner()]++
+	}
+
+	if p.c.MaxItems > 0 && len(p.bins[j]) >= p.c.MaxItems {
+		p.tree.set(j, -1)
+	} else {
+		p.tree.set(j, int64(p.free[j]))
+	}
+}
+
+// pack places items one by one into the bin chosen by the choose function, opening a new bin if it returns -1.
+func pack[T Packable](items []T, c Constraints, choose func(p *packer[T], item T) int) (bins [][]T, remainder []T) {
+	p := &packer[T]{c: c, tree: newFreeTree()}
+
+	for _, item := range items {
+		if item.Size() > c.Capacity {
+			remainder = append(remainder, item)
+			continue
+		}
+
+		p.put(choose(p, item), item)
+	}
+
+	return p.bins, remainder
+}
+
+func decreasing[T Packable](items []T) []T {
+	sorted := slices.Clone(items)
+
+	slices.SortStableFunc(sorted, func(a, b T) int {
+		switch {
+		case a.Size() > b.Size():
+			return -1
+		case a.Size() < b.Size():
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	return sorted
+}
+
+type Summary struct {
+	Bins      int
+	Items     int
+	Remainder int
+	// Fill is the ratio of the packed size to the total capacity of all bins.
+	Fill float64
+}
+
+func Summarize[T Packable](bins [][]T, remainder []T, cap uint64) (s Summary) {
+	var used uint64
+
+	for _, bin := range bins {
+		for _, item := range bin {
+			used += item.Size()
+			s.Items++
+		}
+	}
+
+	s.Bins = len(bins)
+	s.Remainder = len(remainder)
+
+	if s.Bins > 0 && cap > 0 {
+		s.Fill = float64(used) / (float64(s.Bins) * float64(cap))
 	}
 
 	return
 }
+
+func (s Summary) String() string {
+	return fmt.Sprintf(
+		"%d items in %d bins, fill ratio %.2f%%, %d items in remainder",
+		s.Items,
+		s.Bins,
+		s.Fill*100,
+		s.Remainder,
+	)
+}
@@ -0,0 +1,221 @@
+package binpack
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+type item uint64
+
+func (i item) Size() uint64 {
+	return uint64(i)
+}
+
+func (i item) Owner() string {
+	return ""
+}
+
+type ownedItem struct {
+	size  uint64
+	owner string
+}
+
+func (i ownedItem) Size() uint64 {
+	return i.size
+}
+
+func (i ownedItem) Owner() string {
+	return i.owner
+}
+
+func randomItems(n int, maxSize uint64, seed int64) []item {
+	r := rand.New(rand.NewSource(seed))
+	items := make([]item, n)
+
+	for i := range items {
+		items[i] = item(r.Uint64() % maxSize)
+	}
+
+	return items
+}
+
+func randomOwnedItems(n int, maxSize uint64, owners int, seed int64) []ownedItem {
+	r := rand.New(rand.NewSource(seed))
+	items := make([]ownedItem, n)
+
+	for i := range items {
+		items[i] = ownedItem{
+			size:  r.Uint64() % maxSize,
+			owner: fmt.Sprintf("owner%d", r.Intn(owners)),
+		}
+	}
+
+	return items
+}
+
+func TestStrategies(t *testing.T) {
+	c := Constraints{Capacity: 1000, MaxItems: 5, MaxPerOwner: 2}
+
+	items := randomOwnedItems(2000, 1200, 20, 42)
+
+	for _, name := range Strategies() {
+		t.Run(name, func(t *testing.T) {
+			strategy, err := New[ownedItem](name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			bins, remainder := strategy.Pack(items, c)
+
+			var packed int
+
+			for _, bin := range bins {
+				var size uint64
+				owners := map[string]int{}
+
+				for _, it := range bin {
+					size += it.Size()
+					owners[it.Owner()]++
+				}
+
+				assert.NotEmpty(t, bin)
+				assert.LessOrEqual(t, size, c.Capacity)
+				assert.LessOrEqual(t, len(bin), c.MaxItems)
+
+				for _, n := range owners {
+					assert.LessOrEqual(t, n, c.MaxPerOwner)
+				}
+
+				packed += len(bin)
+			}
+
+			for _, it := range remainder {
+				assert.Greater(t, it.Size(), c.Capacity)
+			}
+
+			assert.Equal(t, len(items), packed+len(remainder))
+		})
+	}
+}
+
+func TestStrategies_Decreasing(t *testing.T) {
+	items := []item{2, 5, 4, 7, 1, 3, 8}
+	c := Constraints{Capacity: 10}
+
+	ff, _ := FirstFit(items, c)
+	ffd, _ := FirstFitDecreasing(items, c)
+	bfd, _ := BestFitDecreasing(items, c)
+	nf, _ := NextFit(items, c)
+
+	assert.Equal(t, [][]item{{2, 5, 1}, {4, 3}, {7}, {8}}, ff)
+	assert.Equal(t, [][]item{{8, 2}, {7, 3}, {5, 4, 1}}, ffd)
+	assert.Equal(t, [][]item{{8, 2}, {7, 3}, {5, 4, 1}}, bfd)
+	assert.Equal(t, [][]item{{2, 5}, {4}, {7, 1}, {3}, {8}}, nf)
+}
+
+func TestFirstFit_Constraints(t *testing.T) {
+	items := []ownedItem{
+		{1, "a"}, {1, "a"}, {1, "b"}, {1, "a"}, {1, "c"}, {1, "d"},
+	}
+
+	bins, _ := FirstFit(items, Constraints{Capacity: 10, MaxItems: 3, MaxPerOwner: 1})
+
+	assert.Equal(t, [][]ownedItem{
+		{{1, "a"}, {1, "b"}, {1, "c"}},
+		{{1, "a"}, {1, "d"}},
+		{{1, "a"}},
+	}, bins)
+}
+
+func TestNew_Unknown(t *testing.T) {
+	_, err := New[item]("almost-fit")
+	assert.Error(t, err)
+}
+
+// firstFitLinear is the reference first-fit scanning all open bins for every item.
+func firstFitLinear[T Packable](items []T, c Constraints) (bins [][]T, remainder []T) {
+	return pack(items, c, func(p *packer[T], item T) int {
+		for j := range p.free {
+			if p.fits(j, item) {
+				return j
+			}
+		}
+
+		return -1
+	})
+}
+
+// worstFitLinear is the reference worst-fit scanning all open bins for every item.
+func worstFitLinear[T Packable](items []T, c Constraints) (bins [][]T, remainder []T) {
+	return pack(items, c, func(p *packer[T], item T) int {
+		worst := -1
+
+		for j, f := range p.free {
+			if p.fits(j, item) && (worst == -1 || f > p.free[worst]) {
+				worst = j
+			}
+		}
+
+		return worst
+	})
+}
+
+func TestFirstFit_Indexed(t *testing.T) {
+	for seed := int64(0); seed < 5; seed++ {
+		for _, c := range []Constraints{
+			{Capacity: 250},
+			{Capacity: 250, MaxItems: 4},
+			{Capacity: 250, MaxItems: 6, MaxPerOwner: 1},
+		} {
+			items := randomOwnedItems(5000, 300, 50, seed)
+
+			bins, remainder := FirstFit(items, c)
+			expectedBins, expectedRemainder := firstFitLinear(items, c)
+
+			assert.Equal(t, expectedBins, bins)
+			assert.Equal(t, expectedRemainder, remainder)
+
+			bins, remainder = WorstFit(items, c)
+			expectedBins, expectedRemainder = worstFitLinear(items, c)
+
+			assert.Equal(t, expectedBins, bins)
+			assert.Equal(t, expectedRemainder, remainder)
+		}
+	}
+}
+
+func TestFirstFit_ZeroSize(t *testing.T) {
+	bins, remainder := FirstFit([]item{0, 10, 0, 3, 10}, Constraints{Capacity: 10})
+
+	assert.Equal(t, [][]item{{0, 10, 0}, {3}, {10}}, bins)
+	assert.Empty(t, remainder)
+}
+
+func benchmarkFirstFit(b *testing.B, fn StrategyFunc[item], n int) {
+	items := randomItems(n, 1000, 42)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		fn.Pack(items, Constraints{Capacity: 1000})
+	}
+}
+
+func BenchmarkFirstFit(b *testing.B) {
+	for _, n := range []int{1000, 10000, 100000} {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			benchmarkFirstFit(b, FirstFit[item], n)
+		})
+	}
+}
+
+func BenchmarkFirstFitLinear(b *testing.B) {
+	for _, n := range []int{1000, 10000, 100000} {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			benchmarkFirstFit(b, firstFitLinear[item], n)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package binpack
+
+// freeTree is a segment tree over free space of bins in the order they were opened.
+// Every node holds the maximum free space in its subtree, so the leftmost bin an item fits into
+// is found in O(log n) instead of scanning all open bins.
+type freeTree struct {
+	// leaves is the number of leaves, always a power of two
+	leaves int
+	// bins is the number of opened bins
+	bins int
+	// max is the implicit tree with the root at 1 and leaves at [leaves, 2*leaves), unused and closed leaves hold -1
+	max []int64
+}
+
+func newFreeTree() *freeTree {
+	t := &freeTree{leaves: 1}
+	t.max = []int64{-1, -1}
+
+	return t
+}
+
+// open appends a bin with the given free space and returns its index.
+func (t *freeTree) open(free int64) int {
+	if t.bins == t.leaves {
+		t.grow()
+	}
+
+	j := t.bins
+	t.bins++
+	t.set(j, free)
+
+	return j
+}
+
+// first returns the leftmost bin with at least size free that is accepted by ok, or -1 if there is none.
+// A nil ok accepts any bin, then the lookup takes O(log n).
+func (t *freeTree) first(size int64, ok func(j int) bool) int {
+	return t.firstIn(1, size, ok)
+}
+
+func (t *freeTree) firstIn(i int, size int64, ok func(j int) bool) int {
+	if t.max[i] < size {
+		return -1
+	}
+
+	if i >= t.leaves {
+		if j := i - t.leaves; ok == nil || ok(j) {
+			return j
+		}
+
+		return -1
+	}
+
+	if j := t.firstIn(2*i, size, ok); j >= 0 {
+		return j
+	}
+
+	return t.firstIn(2*i+1, size, ok)
+}
+
+// emptiest returns the leftmost bin with the most free space among bins with at least size free
+// that are accepted by ok, or -1 if there is none.
+func (t *freeTree) emptiest(size int64, ok func(j int) bool) int {
+	best, bestFree := -1, int64(-1)
+
+	var visit func(i int)
+	visit = func(i int) {
+		if t.max[i] < size || t.max[i] <= bestFree {
+			return
+		}
+
+		if i >= t.leaves {
+			if j := i - t.leaves; ok == nil || ok(j) {
+				best, bestFree = j, t.max[i]
+			}
+
+			return
+		}
+
+		visit(2 * i)
+		visit(2*i + 1)
+	}
+
+	visit(1)
+
+	return best
+}
+
+func (t *freeTree) set(j int, free int64) {
+	i := t.leaves + j
+	t.max[i] = free
+
+	for i > 1 {
+		i /= 2
+		t.max[i] = max(t.max[2*i], t.max[2*i+1])
+	}
+}
+
+func (t *freeTree) grow() {
+	leaves := t.leaves * 2
+	tree := make([]int64, 2*leaves)
+
+	for i := range tree {
+		tree[i] = -1
+	}
+
+	copy(tree[leaves:], t.max[t.leaves:t.leaves+t.bins])
+
+	for i := leaves - 1; i >= 1; i-- {
+		tree[i] = max(tree[2*i], tree[2*i+1])
+	}
+
+	t.leaves = leaves
+	t.max = tree
+}
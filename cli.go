@@ -1,11 +1,15 @@
 package main
 
 import (
+	"github.com/gaarutyunov/gh-exporter/binpack"
+	"github.com/gaarutyunov/gh-exporter/gh"
 	"github.com/gaarutyunov/gh-exporter/internal"
+	"github.com/gaarutyunov/gh-exporter/plan"
 	"github.com/go-git/go-git/v5/plumbing/cache"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"strings"
+	"time"
 )
 
 var (
@@ -53,6 +57,34 @@ var (
 		Short: "Scan repositories index from file",
 		RunE:  internal.Scan,
 	}
+
+	dedupeCmd = &cobra.Command{
+		Use:   "dedupe",
+		Short: "Remove duplicated repositories from search results",
+		Long:  "This command removes repositories listed more than once and optionally collapses forks onto their parent and mirrors sharing a root commit",
+		RunE:  internal.Dedupe,
+	}
+
+	inspectCmd = &cobra.Command{
+		Use:   "inspect",
+		Short: "Show statistics of a plan",
+		Long:  "This command prints the number of bins, a fill histogram, the largest and smallest bins, the remainder and the estimated download size of a plan, or lists repositories of a single bin",
+		RunE:  internal.Inspect,
+	}
+
+	retryCmd = &cobra.Command{
+		Use:   "retry",
+		Short: "Turn a failures report back into a plan or results",
+		Long:  "This command converts failures reported by export or scan into a plan that can be exported or results that can be scanned again",
+		RunE:  internal.Retry,
+	}
+
+	filterCmd = &cobra.Command{
+		Use:   "filter",
+		Short: "Filter search results by recorded fields",
+		Long:  "This command keeps repositories from a results file that satisfy all conditions, e.g. stars>=50, license in (mit, apache-2.0) or !fork",
+		RunE:  internal.Filter,
+	}
 )
 
 func init() {
@@ -66,38 +98,108 @@ func init() {
 	// search
 	pFlags = searchCmd.PersistentFlags()
 	pFlags.StringP("query", "q", "language:Python", "GitHub repos search query")
-	pFlags.StringP("out", "o", "results.csv", "Search results file")
+	pFlags.StringP("out", "o", "results.jsonl", "Search results file")
 	pFlags.Int64P("limit", "l", -1, "Maximum number of repositories to export")
 	pFlags.IntP("burst", "b", 1, "Rate limiter burst")
+	pFlags.Bool("resume", false, "Resume search from the checkpoint next to the results file")
+	pFlags.StringSlice("slice", nil, "Split queries over 1000 results by qualifier ranges: created, size")
+	pFlags.String("backend", "rest", "GitHub API backend: rest, graphql")
 
 	// plan
 	pFlags = planCmd.PersistentFlags()
 	pFlags.Uint64P("capacity", "c", uint64(cache.GiByte), "Repository group capacity in bytes")
-	pFlags.StringP("in", "i", "results.csv", "Search results input for planning")
-	pFlags.StringP("out", "o", "plan.csv", "Plan file path")
+	pFlags.StringP("in", "i", "results.jsonl", "Search results input for planning")
+	pFlags.StringP("out", "o", "plan.json", "Plan file path")
+	pFlags.Int("max-repos", 0, "Maximum number of repositories in a group, 0 for unlimited")
+	pFlags.Int("max-per-owner", 0, "Maximum number of repositories of the same owner in a group, 0 for unlimited")
+	pFlags.StringP("strategy", "s", binpack.FirstFitName, "Bin packing strategy: "+strings.Join(binpack.Strategies(), ", "))
+	pFlags.String("remainder", string(plan.PolicyKeep), "Policy for repositories larger than capacity: keep, dedicated, huge, exclude")
+	pFlags.String("excluded", "excluded.jsonl", "Report of repositories excluded by the exclude remainder policy")
+
+	// inspect
+	pFlags = inspectCmd.PersistentFlags()
+	pFlags.StringP("file", "f", "plan.json", "Plan file path")
+	pFlags.StringP("bin", "b", "", "List repositories of a bin by its ID or index, e.g. bin-0003, 3 or remainder")
+	pFlags.Int("buckets", 10, "Number of fill histogram buckets")
 
 	// export
 	pFlags = exportCmd.PersistentFlags()
 	pFlags.StringP("identity", "i", "~/.ssh/id_rsa", "SSH key path for cloning")
+	pFlags.Bool("ssh-agent", false, "Authenticate with keys of the running ssh-agent instead of the identity file")
+	pFlags.String("passphrase-env", "SSH_KEY_PASSPHRASE", "Environment variable with the identity passphrase, prompted for if not set")
+	pFlags.StringSlice("known-hosts", nil, "Known hosts files for host key verification, defaults to SSH_KNOWN_HOSTS or ~/.ssh/known_hosts")
+	pFlags.String("transport", "ssh", "Cloning transport: ssh, https with GITHUB_TOKEN, anonymous https")
 	pFlags.StringP("out", "o", "repos", "Output directory")
-	pFlags.StringP("file", "f", "plan.csv", "Plan file path")
-	pFlags.StringP("pattern", "p", "*.py", "Cloning file name pattern")
+	pFlags.StringP("file", "f", "plan.json", "Plan file path")
+	pFlags.StringArray("include", nil, "Keep files matching the gitignore-style pattern, can be repeated, defaults to *.py")
+	pFlags.StringArray("exclude", nil, "Drop files matching the gitignore-style pattern, can be repeated, takes precedence over includes")
+	pFlags.String("patterns-file", "", "File with include patterns, one per line, lines starting with ! are excludes")
+	pFlags.StringP("pattern", "p", "", "Cloning file name pattern")
+	_ = pFlags.MarkDeprecated("pattern", "use --include instead")
+	pFlags.StringSlice("language", nil, "Keep only files of these languages detected by file name, shebang and extension, e.g. Python,Go")
+	pFlags.Int64("max-file-size", 0, "Drop files larger than this size in bytes, 0 for unlimited")
+	pFlags.Bool("skip-binary", false, "Drop binary files, detected by a NUL byte in their first 8000 bytes")
+	pFlags.Bool("skip-generated", false, "Drop generated files, marked with \"Code generated ... DO NOT EDIT\" or @generated")
+	pFlags.Int("depth", gh.Depth, "Number of commits of history to fetch, 0 for full history")
+	pFlags.Bool("single-branch", true, "Fetch only the default branch without tags")
+	pFlags.Bool("exact", false, "Fetch only the planned commit instead of cloning and checking it out")
 	pFlags.IntP("concurrency", "c", 10, "Cloning concurrency")
 	pFlags.Bool("skip-remainder", false, "Skip exporting remainder")
 	pFlags.Bool("only-remainder", false, "Export only remainder")
 	pFlags.Bool("in-memory", false, "Use in-memory cloning")
+	pFlags.Int("huge-concurrency", 1, "Cloning concurrency for the huge remainder tier")
+	pFlags.String("bins", "", "Export only these bins, e.g. 3,7-12 or remainder")
+	pFlags.String("shard", "", "Export only the i-th of n shards of the plan, e.g. 0/4")
+	pFlags.String("ledger", "", "Export ledger path, defaults to .ledger.jsonl in the output directory")
+	pFlags.Int("retries", 3, "Maximum number of cloning attempts of a repository")
+	pFlags.Duration("backoff", time.Second, "Backoff before the second cloning attempt, doubled after every attempt")
+	pFlags.Duration("max-backoff", time.Minute, "Maximum backoff between cloning attempts")
+	pFlags.String("failures", "", "Report of repositories that failed to export, defaults to the plan file with .failures.jsonl extension")
+	pFlags.String("fail-threshold", "", "Exit with an error when more repositories fail, a count or a percentage, e.g. 10 or 5%")
 
 	// scan
 	pFlags = scanCmd.PersistentFlags()
 	pFlags.IntP("concurrency", "c", 10, "Scanning concurrency")
 	pFlags.StringP("in", "i", "input.spec", "Input file to scan")
-	pFlags.StringP("out", "o", "results.csv", "Output file in search format")
+	pFlags.StringP("out", "o", "results.jsonl", "Output file in search format")
 	pFlags.StringP("format", "f", "%s %s", "Input file format")
+	pFlags.String("backend", "rest", "GitHub API backend: rest, graphql")
+	pFlags.String("failures", "scan_failures.jsonl", "Report of repositories that failed to scan")
+
+	// retry
+	pFlags = retryCmd.PersistentFlags()
+	pFlags.StringP("in", "i", "plan.failures.jsonl", "Failures report of export or scan")
+	pFlags.StringP("out", "o", "retry.json", "Plan or results file to retry")
+	pFlags.StringP("format", "f", "plan", "Output format: plan, results")
+	pFlags.StringSlice("class", nil, "Retry only failures of these classes: transient, not_found, empty, auth, canceled, invalid, unreachable")
+	pFlags.StringSlice("stage", nil, "Retry only failures at these stages: lookup, clone, checkout, filter")
+
+	// filter
+	pFlags = filterCmd.PersistentFlags()
+	pFlags.StringP("in", "i", "results.jsonl", "Search results input for filtering")
+	pFlags.StringP("out", "o", "filtered.jsonl", "Filtered results file")
+	pFlags.StringArrayP("where", "w", nil, "Condition over a recorded field, can be repeated")
+	pFlags.StringSlice("allow-owners", nil, "Keep only repositories of these owners, @file reads owners from a file")
+	pFlags.StringSlice("deny-owners", nil, "Drop repositories of these owners, @file reads owners from a file")
+
+	// dedupe
+	pFlags = dedupeCmd.PersistentFlags()
+	pFlags.StringSliceP("in", "i", []string{"results.jsonl"}, "Search results inputs for deduplication")
+	pFlags.StringP("out", "o", "deduped.jsonl", "Deduplicated results file")
+	pFlags.StringP("report", "r", "dropped.jsonl", "Report of dropped repositories, empty to skip")
+	pFlags.Bool("forks", false, "Collapse forks onto their parent")
+	pFlags.Bool("mirrors", false, "Collapse repositories sharing the root commit, root commits aren't recorded by search and need --resolve")
+	pFlags.Bool("resolve", false, "Fetch fork parents and root commits missing in the results")
+	pFlags.IntP("concurrency", "c", 10, "Resolving concurrency")
 
 	rootCmd.AddCommand(
 		searchCmd,
 		exportCmd,
 		planCmd,
+		inspectCmd,
 		scanCmd,
+		retryCmd,
+		filterCmd,
+		dedupeCmd,
 	)
 }
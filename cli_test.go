@@ -1,19 +1,31 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gaarutyunov/gh-exporter/gh"
 	"github.com/gaarutyunov/gh-exporter/plan"
 	"github.com/gaarutyunov/gh-exporter/utils"
+	"github.com/google/go-github/v45/github"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestSearch_WithLimit(t *testing.T) {
 	cmd := rootCmd
 
-	outFile := filepath.Join(t.TempDir(), "results.csv")
+	outFile := filepath.Join(t.TempDir(), "results.jsonl")
 
 	limit := 10
 
@@ -43,7 +55,7 @@ func TestSearch_WithLimit(t *testing.T) {
 
 func TestSearch_WithLimitAndPagination(t *testing.T) {
 	cmd := rootCmd
-	outFile := filepath.Join(t.TempDir(), "results.csv")
+	outFile := filepath.Join(t.TempDir(), "results.jsonl")
 	limit := 150
 
 	cmd.SetArgs([]string{
@@ -74,7 +86,7 @@ func TestSearch_WithLimitAndPagination(t *testing.T) {
 func TestPlan(t *testing.T) {
 	cmd := rootCmd
 	outFile := filepath.Join("testdata", "results.csv")
-	planFile := filepath.Join(t.TempDir(), "plan.csv")
+	planFile := filepath.Join(t.TempDir(), "plan.json")
 
 	cmd.SetArgs([]string{
 		"plan",
@@ -87,7 +99,7 @@ func TestPlan(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	expectedPlanFile := filepath.Join("testdata", "plan.csv")
+	expectedPlanFile := filepath.Join("testdata", "plan.json")
 
 	expected, err := os.ReadFile(expectedPlanFile)
 	if err != nil {
@@ -102,6 +114,41 @@ func TestPlan(t *testing.T) {
 	assert.Equal(t, string(expected), string(actual))
 }
 
+func TestPlan_JSONLines(t *testing.T) {
+	cmd := rootCmd
+	outFile := filepath.Join("testdata", "results.jsonl")
+	planFile := filepath.Join(t.TempDir(), "plan.json")
+
+	cmd.SetArgs([]string{
+		"plan",
+		"--in", outFile,
+		"--out", planFile,
+	})
+
+	err := cmd.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := plan.Open(planFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, outFile, actual.Source)
+
+	// the legacy and the JSON Lines plans made from the same results
+	for _, name := range []string{"plan.csv", "plan.jsonl"} {
+		expected, err := plan.Open(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, expected.Bins, actual.Bins, name)
+		assert.Equal(t, expected.Remainder, actual.Remainder, name)
+	}
+}
+
 func TestExport_SkipRemainder(t *testing.T) {
 	planFile := filepath.Join("testdata", "plan_small.csv")
 	outDir := t.TempDir()
@@ -133,3 +180,494 @@ func TestExport_SkipRemainder(t *testing.T) {
 
 	assert.Equal(t, total, len(entries))
 }
+
+func TestFilter(t *testing.T) {
+	cmd := rootCmd
+	inFile := filepath.Join("testdata", "results_meta.jsonl")
+	outFile := filepath.Join(t.TempDir(), "filtered.jsonl")
+
+	cmd.SetArgs([]string{
+		"filter",
+		"--in", inFile,
+		"--out", outFile,
+		"--where", "stars>=50",
+		"--where", "!fork",
+		"--where", "license in (mit, apache-2.0)",
+		"--deny-owners", "old",
+	})
+
+	err := cmd.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	open, err := os.Open(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer open.Close()
+
+	var names []string
+
+	for line := range utils.IterLines(open) {
+		repo, err := gh.RepoInfoFromString(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names = append(names, repo.FullName())
+	}
+
+	assert.Equal(t, []string{"public-apis/public-apis", "psf/requests"}, names)
+}
+
+func TestDedupe(t *testing.T) {
+	cmd := rootCmd
+	inFile := filepath.Join("testdata", "results_meta.jsonl")
+	outFile := filepath.Join(t.TempDir(), "deduped.jsonl")
+	reportFile := filepath.Join(t.TempDir(), "dropped.jsonl")
+
+	var stderr bytes.Buffer
+	cmd.SetErr(&stderr)
+	t.Cleanup(func() { cmd.SetErr(nil) })
+
+	cmd.SetArgs([]string{
+		"dedupe",
+		"--in", inFile + "," + inFile,
+		"--out", outFile,
+		"--report", reportFile,
+		"--forks",
+		"--mirrors",
+	})
+
+	err := cmd.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	open, err := os.Open(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer open.Close()
+
+	var names []string
+
+	for line := range utils.IterLines(open) {
+		repo, err := gh.RepoInfoFromString(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names = append(names, repo.FullName())
+	}
+
+	assert.Equal(t, []string{
+		"public-apis/public-apis",
+		"donnemartin/system-design-primer",
+		"vinta/awesome-python",
+		"psf/requests",
+		"old/archived-tool",
+	}, names)
+
+	report, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Contains(t, string(report), `{"full_name":"someone/awesome-python","reason":"fork","kept":"vinta/awesome-python"}`)
+	assert.Contains(t, string(report), `{"full_name":"mirror/requests","reason":"mirror","kept":"psf/requests"}`)
+
+	reportLines, err := utils.LineCounter(bytes.NewReader(report))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 9, reportLines)
+
+	// the fixture records root commits of two repositories only
+	assert.Contains(t, stderr.String(), "Warning: 5 of 7 repositories have no root commit")
+}
+
+func TestPlan_RemainderPolicy(t *testing.T) {
+	cmd := rootCmd
+	inFile := filepath.Join("testdata", "results.csv")
+
+	for _, policy := range []plan.Policy{plan.PolicyDedicated, plan.PolicyExclude} {
+		t.Run(string(policy), func(t *testing.T) {
+			planFile := filepath.Join(t.TempDir(), "plan.json")
+			excludedFile := filepath.Join(t.TempDir(), "excluded.jsonl")
+
+			cmd.SetArgs([]string{
+				"plan",
+				"--in", inFile,
+				"--out", planFile,
+				"--remainder", string(policy),
+				"--excluded", excludedFile,
+			})
+
+			err := cmd.Execute()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fi, err := plan.Open(planFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var remainder []plan.Group
+
+			for group := range fi.Iter(false, true) {
+				remainder = append(remainder, group)
+			}
+
+			switch policy {
+			case plan.PolicyDedicated:
+				assert.Equal(t, plan.PolicyDedicated, fi.Policy)
+				assert.Len(t, remainder, 2)
+
+				for _, group := range remainder {
+					assert.Len(t, group.Repos, 1)
+					assert.True(t, group.IsRemainder())
+				}
+			case plan.PolicyExclude:
+				assert.Empty(t, remainder)
+				assert.Empty(t, fi.Remainder)
+
+				excluded, err := readLines(excludedFile)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				assert.Len(t, excluded, 2)
+			}
+		})
+	}
+}
+
+func readLines(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Split(strings.TrimSpace(string(data)), "\n"), nil
+}
+
+func TestInspect(t *testing.T) {
+	cmd := rootCmd
+	planFile := filepath.Join("testdata", "plan.json")
+
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	t.Cleanup(func() { cmd.SetOut(nil) })
+
+	cmd.SetArgs([]string{
+		"inspect",
+		"--file", planFile,
+	})
+
+	err := cmd.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Contains(t, out.String(), "Bins: 21 with 148 repositories\n")
+	assert.Contains(t, out.String(), "Remainder: 2 repositories, 11.8 GiB (keep)\n")
+	assert.Contains(t, out.String(), "Estimated download: 32.4 GiB\n")
+
+	out.Reset()
+	cmd.SetArgs([]string{
+		"inspect",
+		"--file", planFile,
+		"--bin", "remainder",
+	})
+
+	err = cmd.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+
+	assert.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "odoo/odoo "))
+	assert.True(t, strings.HasPrefix(lines[1], "OpenBB-finance/OpenBB "))
+}
+
+func TestRetry(t *testing.T) {
+	cmd := rootCmd
+	inFile := filepath.Join("testdata", "failures.jsonl")
+	planFile := filepath.Join(t.TempDir(), "retry.json")
+
+	cmd.SetArgs([]string{
+		"retry",
+		"--in", inFile,
+		"--out", planFile,
+		"--class", "transient",
+	})
+
+	err := cmd.Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := plan.Open(planFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, fi.Bins, 1)
+	assert.Equal(t, "a/b", fi.Bins[0][0].FullName())
+	assert.Equal(t, plan.PolicyDedicated, fi.Policy)
+	assert.Equal(t, "big/x", fi.Remainder[0].FullName())
+}
+
+// searchServer is a GitHub REST API stand-in serving search results and head commits of repos.
+type searchServer struct {
+	repos []*github.Repository
+	// failPage is the search results page answered with an error, none if 0.
+	failPage atomic.Int64
+	// rateLimited answers repository lookups as over the rate limit.
+	rateLimited atomic.Bool
+}
+
+func newSearchServer(t *testing.T, n int) *searchServer {
+	s := &searchServer{}
+
+	for i := range n {
+		fullName := fmt.Sprintf("o/r%04d", i)
+
+		s.repos = append(s.repos, &github.Repository{
+			FullName:      github.String(fullName),
+			SSHURL:        github.String(fmt.Sprintf("git@github.com:%s.git", fullName)),
+			Size:          github.Int(i),
+			DefaultBranch: github.String("master"),
+		})
+	}
+
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+
+	baseURL := gh.APIBaseURL
+	gh.APIBaseURL = srv.URL + "/"
+	t.Cleanup(func() { gh.APIBaseURL = baseURL })
+
+	return s
+}
+
+func (s *searchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/search/repositories":
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		page = max(page, 1)
+
+		if int64(page) == s.failPage.Load() {
+			http.Error(w, `{"message": "Server Error"}`, http.StatusInternalServerError)
+			return
+		}
+
+		start := min((page-1)*perPage, len(s.repos))
+		end := min(start+perPage, len(s.repos))
+
+		_ = json.NewEncoder(w).Encode(github.RepositoriesSearchResult{
+			Total:        github.Int(len(s.repos)),
+			Repositories: s.repos[start:end],
+		})
+	case strings.HasSuffix(r.URL.Path, "/commits"):
+		_ = json.NewEncoder(w).Encode([]*github.RepositoryCommit{{SHA: github.String(strings.Repeat("a", 40))}})
+	case strings.HasPrefix(r.URL.Path, "/repos/"):
+		if s.rateLimited.Load() {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+			http.Error(w, `{"message": "API rate limit exceeded"}`, http.StatusForbidden)
+			return
+		}
+
+		for _, repo := range s.repos {
+			if "/repos/"+repo.GetFullName() == r.URL.Path {
+				_ = json.NewEncoder(w).Encode(repo)
+				return
+			}
+		}
+
+		http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+	case r.URL.Path == "/rate_limit":
+		// limiters pace requests by the remaining limit until the reset
+		rate := github.Rate{Limit: 100000, Remaining: 100000, Reset: github.Timestamp{Time: time.Now().Add(time.Minute)}}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"resources": map[string]any{"core": rate, "search": rate, "graphql": rate},
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// resetFlags restores the flags of the command after the test, as commands are shared by tests.
+func resetFlags(t *testing.T, cmd *cobra.Command) {
+	t.Cleanup(func() {
+		cmd.PersistentFlags().VisitAll(func(f *pflag.Flag) {
+			if v, ok := f.Value.(pflag.SliceValue); ok {
+				var values []string
+				if def := strings.Trim(f.DefValue, "[]"); def != "" {
+					values = strings.Split(def, ",")
+				}
+
+				_ = v.Replace(values)
+			} else {
+				_ = f.Value.Set(f.DefValue)
+			}
+
+			f.Changed = false
+		})
+	})
+}
+
+func TestSearch_PageError(t *testing.T) {
+	srv := newSearchServer(t, 150)
+	srv.failPage.Store(2)
+
+	outFile := filepath.Join(t.TempDir(), "results.jsonl")
+
+	cmd := rootCmd
+	resetFlags(t, searchCmd)
+	cmd.SetArgs([]string{
+		"search",
+		"--limit", "150",
+		"--out", outFile,
+	})
+
+	err := cmd.Execute()
+	assert.Error(t, err)
+
+	lines, err := readLines(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, lines, 100)
+}
+
+func TestSearch_Resume(t *testing.T) {
+	srv := newSearchServer(t, 150)
+	srv.failPage.Store(2)
+
+	outFile := filepath.Join(t.TempDir(), "results.jsonl")
+
+	cmd := rootCmd
+	resetFlags(t, searchCmd)
+
+	// the run is interrupted by the failing second page
+	cmd.SetArgs([]string{"search", "--limit", "150", "--out", outFile})
+	assert.Error(t, cmd.Execute())
+
+	lines, err := readLines(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, lines, 100)
+	assert.FileExists(t, outFile+".checkpoint")
+
+	cmd.SetArgs([]string{"search", "--limit", "150", "--out", outFile, "--resume", "--query", "language:Go"})
+	assert.ErrorContains(t, cmd.Execute(), "was made for query")
+
+	srv.failPage.Store(0)
+
+	cmd.SetArgs([]string{"search", "--limit", "150", "--out", outFile, "--resume", "--query", "language:Python"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err = readLines(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, 0, len(lines))
+
+	for _, line := range lines {
+		repo, err := gh.RepoInfoFromString(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names = append(names, repo.FullName())
+	}
+
+	// results are appended once each in search order
+	assert.Len(t, names, 150)
+
+	for i, name := range names {
+		assert.Equal(t, fmt.Sprintf("o/r%04d", i), name)
+	}
+
+	// a new run starts over
+	cmd.SetArgs([]string{"search", "--limit", "150", "--out", outFile, "--resume=false"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err = readLines(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, lines, 150)
+}
+
+func TestScan_Failures(t *testing.T) {
+	srv := newSearchServer(t, 2)
+
+	dir := t.TempDir()
+	inFile := filepath.Join(dir, "input.jsonl")
+	outFile := filepath.Join(dir, "results.jsonl")
+	failuresFile := filepath.Join(dir, "scan_failures.jsonl")
+
+	var in strings.Builder
+	for _, name := range []string{"o/r0000", "o/r0001", "o/missing"} {
+		fmt.Fprintln(&in, gh.NewRepoInfo(name, "", 0))
+	}
+
+	if err := os.WriteFile(inFile, []byte(in.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := rootCmd
+	resetFlags(t, scanCmd)
+	cmd.SetArgs([]string{"scan", "--in", inFile, "--out", outFile, "--failures", failuresFile, "--concurrency", "1"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := readLines(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failures, err := readLines(failuresFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, results, 2)
+	assert.Len(t, failures, 1)
+	assert.Contains(t, failures[0], `"full_name":"o/missing"`)
+	assert.Contains(t, failures[0], `"class":"not_found"`)
+
+	// rate limit errors stop the scan instead of being reported for every repository
+	srv.rateLimited.Store(true)
+
+	assert.Error(t, cmd.Execute())
+
+	data, err := os.ReadFile(failuresFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Empty(t, data)
+}
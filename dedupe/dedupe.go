@@ -0,0 +1,139 @@
+package dedupe
+
+import (
+	"github.com/gaarutyunov/gh-exporter/gh"
+	"github.com/spf13/cast"
+	"strings"
+)
+
+type Reason string
+
+const (
+	// Duplicate is a repository listed more than once under the same full name.
+	Duplicate Reason = "duplicate"
+	// Fork is a fork collapsed onto its parent or onto a more popular fork of the same parent.
+	Fork Reason = "fork"
+	// Mirror is a repository sharing the root commit with a more popular one.
+	Mirror Reason = "mirror"
+)
+
+type Options struct {
+	// Forks collapses forks onto their parent.
+	Forks bool
+	// Mirrors collapses repositories sharing the root commit.
+	Mirrors bool
+}
+
+// Drop describes a removed repository and the one it was collapsed onto.
+type Drop struct {
+	Repo   gh.RepoInfo
+	Reason Reason
+	Kept   string
+}
+
+// Dedupe removes duplicated repositories keeping the order of the remaining ones.
+// Exact duplicates by full name are always removed, the first occurrence is kept.
+// Forks are collapsed onto their parent if it is present, otherwise onto the fork with the most stars.
+// Mirrors are collapsed onto the repository with the most stars. Earlier repositories are preferred on a tie.
+func Dedupe(repos []gh.RepoInfo, opts Options) (kept []gh.RepoInfo, dropped []Drop) {
+	unique := make([]gh.RepoInfo, 0, len(repos))
+	byName := make(map[string]gh.RepoInfo, len(repos))
+
+	for _, repo := range repos {
+		key := strings.ToLower(repo.FullName())
+
+		if first, ok := byName[key]; ok {
+			dropped = append(dropped, Drop{Repo: repo, Reason: Duplicate, Kept: first.FullName()})
+			continue
+		}
+
+		byName[key] = repo
+		unique = append(unique, repo)
+	}
+
+	removed := make(map[string]struct{})
+
+	collapse := func(reason Reason, groupKey func(gh.RepoInfo) string) {
+		groups := make(map[string][]int)
+		var order []string
+
+		for i, repo := range unique {
+			if _, ok := removed[strings.ToLower(repo.FullName())]; ok {
+				continue
+			}
+
+			key := groupKey(repo)
+			if key == "" {
+				continue
+			}
+
+			if _, ok := groups[key]; !ok {
+				order = append(order, key)
+			}
+
+			groups[key] = append(groups[key], i)
+		}
+
+		for _, key := range order {
+			group := groups[key]
+			if len(group) < 2 {
+				continue
+			}
+
+			best := group[0]
+			for _, i := range group[1:] {
+				if stars(unique[i]) > stars(unique[best]) {
+					best = i
+				}
+			}
+
+			for _, i := range group {
+				if strings.ToLower(unique[i].FullName()) == key {
+					best = i
+				}
+			}
+
+			for _, i := range group {
+				if i == best {
+					continue
+				}
+
+				removed[strings.ToLower(unique[i].FullName())] = struct{}{}
+				dropped = append(dropped, Drop{Repo: unique[i], Reason: reason, Kept: unique[best].FullName()})
+			}
+		}
+	}
+
+	if opts.Forks {
+		// a parent is grouped with its forks under its own name and is always kept
+		collapse(Fork, func(repo gh.RepoInfo) string {
+			if parent, ok := repo.Meta(gh.MetaParent); ok && cast.ToString(parent) != "" {
+				return strings.ToLower(cast.ToString(parent))
+			}
+
+			return strings.ToLower(repo.FullName())
+		})
+	}
+
+	if opts.Mirrors {
+		collapse(Mirror, func(repo gh.RepoInfo) string {
+			root, _ := repo.Meta(gh.MetaRootSHA)
+
+			return cast.ToString(root)
+		})
+	}
+
+	for _, repo := range unique {
+		if _, ok := removed[strings.ToLower(repo.FullName())]; !ok {
+			kept = append(kept, repo)
+		}
+	}
+
+	return
+}
+
+func stars(repo gh.RepoInfo) int64 {
+	value, _ := repo.Meta(gh.MetaStars)
+
+	return cast.ToInt64(value)
+}
@@ -0,0 +1,245 @@
+package filter
+
+import (
+	"fmt"
+	"github.com/gaarutyunov/gh-exporter/gh"
+	"github.com/spf13/cast"
+	"regexp"
+	"strings"
+)
+
+// Predicate reports whether the repository should be kept.
+type Predicate func(repo gh.RepoInfo) bool
+
+type operator string
+
+const (
+	opEq    operator = "="
+	opNe    operator = "!="
+	opGt    operator = ">"
+	opGe    operator = ">="
+	opLt    operator = "<"
+	opLe    operator = "<="
+	opIn    operator = "in"
+	opNotIn operator = "not in"
+)
+
+var (
+	fieldRegExp      = regexp.MustCompile(`^!?\s*([A-Za-z_][\w.]*)$`)
+	comparisonRegExp = regexp.MustCompile(`^([A-Za-z_][\w.]*)\s*(>=|<=|!=|==|=|>|<|(?i:not\s+in|in)\b)\s*(.*)$`)
+)
+
+// Parse parses a condition over a recorded field:
+//
+//	stars>=50
+//	license in (mit, apache-2.0)
+//	owner not in (foo, bar)
+//	!fork
+//
+// Strings are compared case-insensitively, numbers numerically.
+// For list fields, such as topics, the condition holds if any element satisfies it.
+func Parse(expr string) (Predicate, error) {
+	expr = strings.TrimSpace(expr)
+
+	if m := fieldRegExp.FindStringSubmatch(expr); m != nil {
+		field := m[1]
+		negate := strings.HasPrefix(expr, "!")
+
+		return func(repo gh.RepoInfo) bool {
+			value, _ := repo.Field(field)
+
+			return truthy(value) != negate
+		}, nil
+	}
+
+	m := comparisonRegExp.FindStringSubmatch(expr)
+	if m == nil {
+		return nil, fmt.Errorf("invalid filter expression: %s", expr)
+	}
+
+	field, rawValue := m[1], strings.TrimSpace(m[3])
+	op := operator(strings.Join(strings.Fields(strings.ToLower(m[2])), " "))
+	if op == "==" {
+		op = opEq
+	}
+
+	switch op {
+	case opIn, opNotIn:
+		if !strings.HasPrefix(rawValue, "(") || !strings.HasSuffix(rawValue, ")") {
+			return nil, fmt.Errorf("invalid filter expression: %s: expected a list in parentheses", expr)
+		}
+
+		var values []string
+		for _, v := range strings.Split(rawValue[1:len(rawValue)-1], ",") {
+			if v = unquote(strings.TrimSpace(v)); v != "" {
+				values = append(values, v)
+			}
+		}
+
+		return func(repo gh.RepoInfo) bool {
+			value, _ := repo.Field(field)
+
+			found := anyOf(value, func(v any) bool {
+				for _, expected := range values {
+					if compare(v, expected) == 0 {
+						return true
+					}
+				}
+
+				return false
+			})
+
+			return found == (op == opIn)
+		}, nil
+	default:
+		expected := unquote(rawValue)
+		if expected == "" {
+			return nil, fmt.Errorf("invalid filter expression: %s: missing value", expr)
+		}
+
+		return func(repo gh.RepoInfo) bool {
+			value, ok := repo.Field(field)
+			if !ok {
+				return op == opNe
+			}
+
+			if op == opNe {
+				return !anyOf(value, func(v any) bool {
+					return compare(v, expected) == 0
+				})
+			}
+
+			return anyOf(value, func(v any) bool {
+				c := compare(v, expected)
+
+				switch op {
+				case opEq:
+					return c == 0
+				case opGt:
+					return c > 0
+				case opGe:
+					return c >= 0
+				case opLt:
+					return c < 0
+				case opLe:
+					return c <= 0
+				}
+
+				return false
+			})
+		}, nil
+	}
+}
+
+// All combines predicates so that a repository is kept only if all of them hold.
+func All(predicates ...Predicate) Predicate {
+	return func(repo gh.RepoInfo) bool {
+		for _, predicate := range predicates {
+			if !predicate(repo) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// Owners keeps repositories of allowed owners, if any are given, that are not denied.
+func Owners(allow, deny []string) Predicate {
+	allowed := make(map[string]struct{}, len(allow))
+	for _, owner := range allow {
+		allowed[strings.ToLower(owner)] = struct{}{}
+	}
+
+	denied := make(map[string]struct{}, len(deny))
+	for _, owner := range deny {
+		denied[strings.ToLower(owner)] = struct{}{}
+	}
+
+	return func(repo gh.RepoInfo) bool {
+		owner := strings.ToLower(repo.Owner())
+
+		if _, ok := denied[owner]; ok {
+			return false
+		}
+
+		if len(allowed) == 0 {
+			return true
+		}
+
+		_, ok := allowed[owner]
+
+		return ok
+	}
+}
+
+// compare compares the field value to the expected value numerically if both are numbers,
+// otherwise as case-insensitive strings.
+func compare(value any, expected string) int {
+	if a, err := cast.ToFloat64E(value); err == nil {
+		if _, isBool := value.(bool); !isBool {
+			if b, err := cast.ToFloat64E(expected); err == nil {
+				switch {
+				case a < b:
+					return -1
+				case a > b:
+					return 1
+				default:
+					return 0
+				}
+			}
+		}
+	}
+
+	return strings.Compare(strings.ToLower(cast.ToString(value)), strings.ToLower(expected))
+}
+
+func anyOf(value any, fn func(any) bool) bool {
+	switch values := value.(type) {
+	case []any:
+		for _, v := range values {
+			if fn(v) {
+				return true
+			}
+		}
+
+		return false
+	case []string:
+		for _, v := range values {
+			if fn(v) {
+				return true
+			}
+		}
+
+		return false
+	default:
+		return fn(value)
+	}
+}
+
+func truthy(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []any:
+		return len(v) > 0
+	case []string:
+		return len(v) > 0
+	default:
+		f, err := cast.ToFloat64E(v)
+
+		return err != nil || f != 0
+	}
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+
+	return s
+}
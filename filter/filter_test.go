@@ -0,0 +1,48 @@
+package filter
+
+import (
+	"github.com/gaarutyunov/gh-exporter/gh"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	repo, err := gh.RepoInfoFromString(`{"v":2,"full_name":"psf/requests","ssh_url":"git@github.com:psf/requests.git","size":13000,"meta":{"fork":false,"license":"Apache-2.0","stars":52000,"topics":["python","http"],"pushed_at":"2024-12-03T09:00:00Z"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for expr, expected := range map[string]bool{
+		"stars>=50":                    true,
+		"stars < 50":                   false,
+		"stars==52000":                 true,
+		"size<500000":                  true,
+		"!fork":                        true,
+		"fork":                         false,
+		"license in (mit, apache-2.0)": true,
+		"license IN ('MIT')":           false,
+		"license not in (mit)":         true,
+		"owner = PSF":                  true,
+		"owner != psf":                 false,
+		"topics = http":                true,
+		"topics in (rust, go)":         false,
+		"topics not in (rust, go)":     true,
+		"pushed_at >= 2024-01-01":      true,
+		"missing = 1":                  false,
+		"missing != 1":                 true,
+		"!missing":                     true,
+		"full_name = \"psf/requests\"": true,
+	} {
+		predicate, err := Parse(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, expected, predicate(repo), expr)
+	}
+
+	for _, expr := range []string{"", "stars >=", "license in mit", ">= 5"} {
+		_, err := Parse(expr)
+		assert.Error(t, err, expr)
+	}
+}
@@ -2,8 +2,10 @@ package gh
 
 import (
 	"context"
+	"fmt"
 	"github.com/google/go-github/v45/github"
 	"golang.org/x/oauth2"
+	"net/url"
 	"os"
 )
 
@@ -11,11 +13,49 @@ type Client struct {
 	*github.Client
 }
 
+// APIBaseURL is the GitHub API URL with a trailing slash, the public API is used if empty.
+var APIBaseURL = ""
+
 func NewClient(ctx context.Context) *Client {
-	return &Client{github.NewClient(oauth2.NewClient(
+	client := github.NewClient(oauth2.NewClient(
 		ctx,
 		oauth2.StaticTokenSource(
 			&oauth2.Token{AccessToken: os.Getenv("GITHUB_TOKEN")},
 		),
-	))}
+	))
+
+	if APIBaseURL != "" {
+		client.BaseURL, _ = url.Parse(APIBaseURL)
+	}
+
+	return &Client{client}
+}
+
+// RootCommit returns the SHA of the oldest commit reachable from the branch.
+func (c *Client) RootCommit(ctx context.Context, owner, name, branch string) (string, error) {
+	opts := &github.CommitsListOptions{
+		SHA: branch,
+		ListOptions: github.ListOptions{
+			PerPage: 1,
+		},
+	}
+
+	commits, resp, err := c.Repositories.ListCommits(ctx, owner, name, opts)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.LastPage > 0 {
+		opts.Page = resp.LastPage
+
+		if commits, _, err = c.Repositories.ListCommits(ctx, owner, name, opts); err != nil {
+			return "", err
+		}
+	}
+
+	if len(commits) == 0 {
+		return "", fmt.Errorf("no commits in %s/%s", owner, name)
+	}
+
+	return commits[0].GetSHA(), nil
 }
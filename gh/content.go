@@ -0,0 +1,70 @@
+package gh
+
+import (
+	"bytes"
+	"errors"
+	"github.com/go-git/go-billy/v5"
+	"io"
+	"io/fs"
+	"regexp"
+)
+
+// sniffLen is the length of the file head inspected by content filters, as git does to detect binary files.
+const sniffLen = 8000
+
+// GeneratedMarker matches comments marking generated code, e.g. "Code generated by protoc-gen-go. DO NOT EDIT."
+// or "Generated by the protocol buffer compiler.  DO NOT EDIT!" and `@generated`.
+var GeneratedMarker = regexp.MustCompile(`[Gg]enerated\b.*\bDO NOT EDIT\b|@generated\b`)
+
+// ContentFilter drops files by their size and content.
+type ContentFilter struct {
+	// MaxSize is the maximum file size in bytes, 0 for unlimited.
+	MaxSize int64
+	// Binary drops files containing a NUL byte in their head.
+	Binary bool
+	// Generated drops files with a generated code marker in their head.
+	Generated bool
+}
+
+// Keep tells whether the file is kept, the file is read only if its content is inspected.
+func (f ContentFilter) Keep(fs billy.Filesystem, path string, info fs.FileInfo) (bool, error) {
+	if f.MaxSize > 0 && info.Size() > f.MaxSize {
+		return false, nil
+	}
+
+	if !f.Binary && !f.Generated {
+		return true, nil
+	}
+
+	head, err := readHead(fs, path)
+	if err != nil {
+		return false, err
+	}
+
+	if f.Binary && bytes.IndexByte(head, 0) >= 0 {
+		return false, nil
+	}
+
+	if f.Generated && GeneratedMarker.Match(head) {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func readHead(fs billy.Filesystem, path string) ([]byte, error) {
+	fi, err := fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fi.Close()
+
+	head := make([]byte, sniffLen)
+
+	n, err := io.ReadFull(fi, head)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = nil
+	}
+
+	return head[:n], err
+}
@@ -0,0 +1,50 @@
+package gh
+
+import (
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestContentFilter_Keep(t *testing.T) {
+	all := ContentFilter{MaxSize: 1 << 20, Binary: true, Generated: true}
+
+	for _, tc := range []struct {
+		content string
+		filter  ContentFilter
+		keep    bool
+	}{
+		{"import os\n", all, true},
+		{strings.Repeat("x", 101), ContentFilter{MaxSize: 100}, false},
+		{strings.Repeat("x", 100), ContentFilter{MaxSize: 100}, true},
+		{"\x00\x01\x02", all, false},
+		{"\x00\x01\x02", ContentFilter{}, true},
+		{strings.Repeat("x\n", sniffLen) + "\x00", all, true},
+		{"# -*- coding: utf-8 -*-\n# Generated by the protocol buffer compiler.  DO NOT EDIT!\n", all, false},
+		{"// Code generated by protoc-gen-go. DO NOT EDIT.\n", all, false},
+		{"// Code generated by protoc-gen-go. DO NOT EDIT.\n", ContentFilter{Binary: true}, true},
+		{"# @generated by a tool\n", all, false},
+		{"# Generated docs are published separately, edit freely\n", all, true},
+		{"# @generated_at is a field\n", all, true},
+	} {
+		fs := memfs.New()
+
+		if err := util.WriteFile(fs, "file", []byte(tc.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := fs.Stat("file")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		keep, err := tc.filter.Keep(fs, "file", info)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tc.keep, keep, tc.content[:min(len(tc.content), 40)])
+	}
+}
@@ -0,0 +1,149 @@
+package gh
+
+import (
+	"context"
+	"errors"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/google/go-github/v45/github"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned for repositories missing in lookup results.
+	ErrNotFound = errors.New("repository not found")
+	// ErrCommitUnreachable is returned when the planned commit can't be fetched or checked out.
+	ErrCommitUnreachable = errors.New("planned commit is unreachable")
+	// ErrRateLimited is returned for GraphQL responses rejected by the rate limit.
+	ErrRateLimited = errors.New("rate limited")
+)
+
+// Stage is the step of processing a repository an error happened at.
+type Stage string
+
+const (
+	StageLookup   Stage = "lookup"
+	StageClone    Stage = "clone"
+	StageCheckout Stage = "checkout"
+	StageFilter   Stage = "filter"
+)
+
+// StageError annotates an error with the stage it happened at.
+type StageError struct {
+	Stage Stage
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return string(e.Stage) + ": " + e.Err.Error()
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+func withStage(stage Stage, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &StageError{Stage: stage, Err: err}
+}
+
+// StageOf returns the stage of the error or the fallback if it isn't annotated.
+func StageOf(err error, fallback Stage) Stage {
+	var stageErr *StageError
+	if errors.As(err, &stageErr) {
+		return stageErr.Stage
+	}
+
+	return fallback
+}
+
+// ErrorClass is a coarse classification of export errors.
+type ErrorClass string
+
+const (
+	ClassNotFound ErrorClass = "not_found"
+	ClassEmpty    ErrorClass = "empty"
+	ClassAuth     ErrorClass = "auth"
+	ClassCanceled ErrorClass = "canceled"
+	ClassInvalid  ErrorClass = "invalid"
+	// ClassUnreachable is a planned commit missing in the repository, e.g. after a force push.
+	ClassUnreachable ErrorClass = "unreachable"
+	// ClassTransient covers everything else, such as connection resets and timeouts.
+	ClassTransient ErrorClass = "transient"
+)
+
+func Classify(err error) ErrorClass {
+	var responseErr *github.ErrorResponse
+
+	switch {
+	case err == nil:
+		return ""
+	case IsRateLimited(err):
+		return ClassTransient
+	case errors.As(err, &responseErr) && responseErr.Response != nil:
+		return classifyStatus(responseErr.Response.StatusCode)
+	case errors.Is(err, ErrNotFound), errors.Is(err, transport.ErrRepositoryNotFound):
+		return ClassNotFound
+	case errors.Is(err, ErrCommitUnreachable):
+		return ClassUnreachable
+	case errors.Is(err, transport.ErrEmptyRemoteRepository):
+		return ClassEmpty
+	case errors.Is(err, transport.ErrAuthenticationRequired),
+		errors.Is(err, transport.ErrAuthorizationFailed),
+		errors.Is(err, transport.ErrInvalidAuthMethod),
+		strings.Contains(err.Error(), "unable to authenticate"),
+		isHostKeyError(err):
+		return ClassAuth
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return ClassCanceled
+	case errors.Is(err, filepath.ErrBadPattern), errors.Is(err, git.ErrExactSHA1NotSupported):
+		return ClassInvalid
+	default:
+		return ClassTransient
+	}
+}
+
+// isHostKeyError tells whether the host key of the server is unknown, changed or revoked in known hosts.
+// The message is checked as well, since transports may flatten the error while wrapping it.
+func isHostKeyError(err error) bool {
+	var keyErr *knownhosts.KeyError
+	var revokedErr *knownhosts.RevokedError
+
+	return errors.As(err, &keyErr) || errors.As(err, &revokedErr) || strings.Contains(err.Error(), "knownhosts: ")
+}
+
+func classifyStatus(code int) ErrorClass {
+	switch {
+	case code == http.StatusNotFound, code == http.StatusGone, code == http.StatusUnavailableForLegalReasons:
+		return ClassNotFound
+	case code == http.StatusUnauthorized:
+		return ClassAuth
+	case code == http.StatusTooManyRequests, code >= http.StatusInternalServerError:
+		return ClassTransient
+	default:
+		return ClassInvalid
+	}
+}
+
+// IsRateLimited tells whether the API rejected the request by the primary or the secondary rate limit.
+func IsRateLimited(err error) bool {
+	var rateLimitErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	var responseErr *github.ErrorResponse
+
+	return errors.As(err, &rateLimitErr) ||
+		errors.As(err, &abuseErr) ||
+		errors.Is(err, ErrRateLimited) ||
+		errors.As(err, &responseErr) && responseErr.Response != nil && responseErr.Response.StatusCode == http.StatusTooManyRequests
+}
+
+// IsRetryable tells whether the operation may succeed if repeated.
+func IsRetryable(err error) bool {
+	return Classify(err) == ClassTransient
+}
@@ -0,0 +1,41 @@
+package gh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/google/go-github/v45/github"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"net/http"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	for _, tc := range []struct {
+		err      error
+		expected ErrorClass
+	}{
+		{nil, ""},
+		{withStage(StageLookup, ErrNotFound), ClassNotFound},
+		{withStage(StageClone, transport.ErrRepositoryNotFound), ClassNotFound},
+		{withStage(StageClone, transport.ErrEmptyRemoteRepository), ClassEmpty},
+		{withStage(StageClone, transport.ErrAuthenticationRequired), ClassAuth},
+		{withStage(StageClone, errors.New("ssh: handshake failed: ssh: unable to authenticate")), ClassAuth},
+		{withStage(StageClone, fmt.Errorf("ssh: handshake failed: %w", &knownhosts.KeyError{})), ClassAuth},
+		{withStage(StageClone, fmt.Errorf("ssh: handshake failed: %w", &knownhosts.KeyError{Want: []knownhosts.KnownKey{{}}})), ClassAuth},
+		{withStage(StageClone, fmt.Errorf("ssh: handshake failed: %w", &knownhosts.RevokedError{})), ClassAuth},
+		{withStage(StageClone, errors.New("ssh: handshake failed: knownhosts: key mismatch")), ClassAuth},
+		{withStage(StageCheckout, fmt.Errorf("%w: abc", ErrCommitUnreachable)), ClassUnreachable},
+		{withStage(StageClone, context.Canceled), ClassCanceled},
+		{withStage(StageClone, errors.New("read: connection reset by peer")), ClassTransient},
+		{fmt.Errorf("graphql: %w", ErrRateLimited), ClassTransient},
+		{&github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusUnavailableForLegalReasons}}, ClassNotFound},
+		{&github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusBadGateway}}, ClassTransient},
+	} {
+		assert.Equal(t, tc.expected, Classify(tc.err), "%v", tc.err)
+	}
+
+	assert.False(t, IsRetryable(withStage(StageClone, &knownhosts.KeyError{})))
+}
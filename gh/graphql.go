@@ -0,0 +1,310 @@
+package gh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// GraphQLPageSize is the maximum number of nodes GitHub returns in a single GraphQL connection page.
+const GraphQLPageSize = 100
+
+const repositoryFragment = `
+fragment repo on Repository {
+  nameWithOwner
+  sshUrl
+  diskUsage
+  stargazerCount
+  forkCount
+  primaryLanguage {
+    name
+  }
+  licenseInfo {
+    spdxId
+  }
+  repositoryTopics(first: 20) {
+    nodes {
+      topic {
+        name
+      }
+    }
+  }
+  isArchived
+  isFork
+  isTemplate
+  parent {
+    nameWithOwner
+  }
+  pushedAt
+  defaultBranchRef {
+    name
+    target {
+      oid
+    }
+  }
+}`
+
+const searchQuery = `
+query($q: String!, $first: Int!, $after: String) {
+  search(query: $q, type: REPOSITORY, first: $first, after: $after) {
+    repositoryCount
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+    nodes {
+      ...repo
+    }
+  }
+}` + repositoryFragment
+
+type GraphQLRepository struct {
+	NameWithOwner   string `json:"nameWithOwner"`
+	SSHURL          string `json:"sshUrl"`
+	DiskUsage       uint64 `json:"diskUsage"`
+	StargazerCount  int    `json:"stargazerCount"`
+	ForkCount       int    `json:"forkCount"`
+	PrimaryLanguage *struct {
+		Name string `json:"name"`
+	} `json:"primaryLanguage"`
+	LicenseInfo *struct {
+		SpdxID string `json:"spdxId"`
+	} `json:"licenseInfo"`
+	RepositoryTopics struct {
+		Nodes []struct {
+			Topic struct {
+				Name string `json:"name"`
+			} `json:"topic"`
+		} `json:"nodes"`
+	} `json:"repositoryTopics"`
+	IsArchived bool `json:"isArchived"`
+	IsFork     bool `json:"isFork"`
+	IsTemplate bool `json:"isTemplate"`
+	Parent     *struct {
+		NameWithOwner string `json:"nameWithOwner"`
+	} `json:"parent"`
+	PushedAt         *time.Time `json:"pushedAt"`
+	DefaultBranchRef *struct {
+		Name   string `json:"name"`
+		Target struct {
+			OID string `json:"oid"`
+		} `json:"target"`
+	} `json:"defaultBranchRef"`
+}
+
+func (r GraphQLRepository) DefaultBranch() string {
+	if r.DefaultBranchRef == nil {
+		return ""
+	}
+
+	return r.DefaultBranchRef.Name
+}
+
+func (r GraphQLRepository) HeadOID() string {
+	if r.DefaultBranchRef == nil {
+		return ""
+	}
+
+	return r.DefaultBranchRef.Target.OID
+}
+
+// RepoInfo converts the repository to RepoInfo pinned to the head commit of its default branch
+// with its metadata recorded.
+func (r GraphQLRepository) RepoInfo() RepoInfo {
+	var language, license, pushedAt string
+
+	if r.PrimaryLanguage != nil {
+		language = r.PrimaryLanguage.Name
+	}
+
+	if r.LicenseInfo != nil {
+		license = r.LicenseInfo.SpdxID
+	}
+
+	if r.PushedAt != nil {
+		pushedAt = r.PushedAt.UTC().Format(time.RFC3339)
+	}
+
+	topics := make([]string, 0, len(r.RepositoryTopics.Nodes))
+	for _, node := range r.RepositoryTopics.Nodes {
+		topics = append(topics, node.Topic.Name)
+	}
+
+	info := NewRepoInfo(r.NameWithOwner, r.SSHURL, r.DiskUsage).WithSHA(r.HeadOID())
+
+	if r.Parent != nil {
+		info = info.WithMeta(MetaParent, r.Parent.NameWithOwner)
+	}
+
+	return info.withMetadata(map[string]any{
+		MetaStars:         r.StargazerCount,
+		MetaForks:         r.ForkCount,
+		MetaLanguage:      language,
+		MetaLicense:       license,
+		MetaTopics:        topics,
+		MetaArchived:      r.IsArchived,
+		MetaFork:          r.IsFork,
+		MetaTemplate:      r.IsTemplate,
+		MetaPushedAt:      pushedAt,
+		MetaDefaultBranch: r.DefaultBranch(),
+	})
+}
+
+type GraphQLSearchPage struct {
+	Total        int
+	Repositories []GraphQLRepository
+	// Next is the cursor of the following page, empty if this is the last one.
+	Next string
+}
+
+type graphQLRequest struct {
+	Query     string         `json:"query"`
+	Variables map[string]any `json:"variables,omitempty"`
+}
+
+// graphQLRateLimited is the type of errors of requests over the rate limit.
+const graphQLRateLimited = "RATE_LIMITED"
+
+type graphQLError struct {
+	Type    string `json:"type"`
+	Path    []any  `json:"path"`
+	Message string `json:"message"`
+}
+
+type graphQLResponse[T any] struct {
+	Data   T              `json:"data"`
+	Errors []graphQLError `json:"errors"`
+}
+
+func (r graphQLResponse[T]) err(ignore ...string) error {
+	var errs []error
+
+outer:
+	for _, e := range r.Errors {
+		for _, t := range ignore {
+			if e.Type == t {
+				continue outer
+			}
+		}
+
+		if e.Type == graphQLRateLimited {
+			errs = append(errs, fmt.Errorf("graphql: %w: %s", ErrRateLimited, e.Message))
+			continue
+		}
+
+		errs = append(errs, fmt.Errorf("graphql: %s", e.Message))
+	}
+
+	return errors.Join(errs...)
+}
+
+func graphQL[T any](ctx context.Context, c *Client, query string, variables map[string]any) (res graphQLResponse[T], err error) {
+	req, err := c.NewRequest(http.MethodPost, "graphql", graphQLRequest{
+		Query:     query,
+		Variables: variables,
+	})
+	if err != nil {
+		return
+	}
+
+	_, err = c.Do(ctx, req, &res)
+
+	return
+}
+
+// SearchRepositories fetches a page of repositories matching the search query starting after the cursor.
+func (c *Client) SearchRepositories(ctx context.Context, query string, after string, first int) (page GraphQLSearchPage, err error) {
+	variables := map[string]any{
+		"q":     query,
+		"first": min(first, GraphQLPageSize),
+	}
+	if after != "" {
+		variables["after"] = after
+	}
+
+	res, err := graphQL[struct {
+		Search struct {
+			RepositoryCount int `json:"repositoryCount"`
+			PageInfo        struct {
+				HasNextPage bool   `json:"hasNextPage"`
+				EndCursor   string `json:"endCursor"`
+			} `json:"pageInfo"`
+			Nodes []GraphQLRepository `json:"nodes"`
+		} `json:"search"`
+	}](ctx, c, searchQuery, variables)
+	if err != nil {
+		return
+	}
+
+	if err = res.err(); err != nil {
+		return
+	}
+
+	search := res.Data.Search
+
+	page.Total = search.RepositoryCount
+	page.Repositories = search.Nodes
+
+	if search.PageInfo.HasNextPage {
+		page.Next = search.PageInfo.EndCursor
+	}
+
+	return
+}
+
+// LookupRepositories fetches up to GraphQLPageSize repositories by their full names in a single request.
+// Repositories that don't exist are omitted from the result.
+func (c *Client) LookupRepositories(ctx context.Context, fullNames []string) (map[string]GraphQLRepository, error) {
+	if len(fullNames) == 0 {
+		return map[string]GraphQLRepository{}, nil
+	} else if len(fullNames) > GraphQLPageSize {
+		return nil, fmt.Errorf("can't lookup more than %d repositories at once, got %d", GraphQLPageSize, len(fullNames))
+	}
+
+	var (
+		params     []string
+		selections []string
+		variables  = map[string]any{}
+	)
+
+	for i, fullName := range fullNames {
+		owner, name, ok := strings.Cut(fullName, "/")
+		if !ok {
+			return nil, fmt.Errorf("invalid repository name: %s", fullName)
+		}
+
+		params = append(params, fmt.Sprintf("$o%d: String!, $n%d: String!", i, i))
+		selections = append(selections, fmt.Sprintf("r%d: repository(owner: $o%d, name: $n%d) { ...repo }", i, i, i))
+		variables[fmt.Sprintf("o%d", i)] = owner
+		variables[fmt.Sprintf("n%d", i)] = name
+	}
+
+	query := fmt.Sprintf(
+		"query(%s) {\n  %s\n}%s",
+		strings.Join(params, ", "),
+		strings.Join(selections, "\n  "),
+		repositoryFragment,
+	)
+
+	res, err := graphQL[map[string]*GraphQLRepository](ctx, c, query, variables)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = res.err("NOT_FOUND"); err != nil {
+		return nil, err
+	}
+
+	repos := make(map[string]GraphQLRepository, len(fullNames))
+
+	for i, fullName := range fullNames {
+		if repo := res.Data[fmt.Sprintf("r%d", i)]; repo != nil {
+			repos[fullName] = *repo
+		}
+	}
+
+	return repos, nil
+}
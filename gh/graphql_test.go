@@ -0,0 +1,184 @@
+package gh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/go-github/v45/github"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+var graphQLRepos = map[string]string{
+	"public-apis/public-apis":          "274ecf0e19e8da03197bdda8f2c5be307ad6aa69",
+	"donnemartin/system-design-primer": "40d5d2edccd00b4a66fb0e24d887d8b1a0d7ea0e",
+	"vinta/awesome-python":             "2252650cfdff3782d5a85458507fe9ec6edde7a4",
+}
+
+var graphQLOrder = []string{
+	"public-apis/public-apis",
+	"donnemartin/system-design-primer",
+	"vinta/awesome-python",
+}
+
+func graphQLNode(fullName string) map[string]any {
+	return map[string]any{
+		"nameWithOwner":  fullName,
+		"sshUrl":         fmt.Sprintf("git@github.com:%s.git", fullName),
+		"diskUsage":      len(fullName),
+		"stargazerCount": len(fullName) * 10,
+		"licenseInfo":    map[string]any{"spdxId": "MIT"},
+		"repositoryTopics": map[string]any{
+			"nodes": []any{map[string]any{"topic": map[string]any{"name": "python"}}},
+		},
+		"isFork":   false,
+		"pushedAt": "2024-12-01T10:00:00Z",
+		"defaultBranchRef": map[string]any{
+			"name":   "master",
+			"target": map[string]any{"oid": graphQLRepos[fullName]},
+		},
+	}
+}
+
+// newGraphQLServer starts a GraphQL stand-in serving search with cursors over graphQLOrder and repository lookups.
+func newGraphQLServer(t *testing.T) *Client {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/graphql" {
+			http.NotFound(w, r)
+			return
+		}
+
+		var req graphQLRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var res graphQLResponse[map[string]any]
+		res.Data = map[string]any{}
+
+		if strings.Contains(req.Query, "search(") {
+			first := int(req.Variables["first"].(float64))
+			start := 0
+			if after, ok := req.Variables["after"].(string); ok {
+				_, _ = fmt.Sscanf(after, "cursor%d", &start)
+			}
+			end := min(start+first, len(graphQLOrder))
+
+			nodes := make([]any, 0, end-start)
+			for _, fullName := range graphQLOrder[start:end] {
+				nodes = append(nodes, graphQLNode(fullName))
+			}
+
+			res.Data["search"] = map[string]any{
+				"repositoryCount": len(graphQLOrder),
+				"pageInfo": map[string]any{
+					"hasNextPage": end < len(graphQLOrder),
+					"endCursor":   fmt.Sprintf("cursor%d", end),
+				},
+				"nodes": nodes,
+			}
+		} else {
+			for i := 0; ; i++ {
+				owner, ok := req.Variables[fmt.Sprintf("o%d", i)].(string)
+				if !ok {
+					break
+				}
+
+				fullName := owner + "/" + req.Variables[fmt.Sprintf("n%d", i)].(string)
+				alias := fmt.Sprintf("r%d", i)
+
+				if _, ok := graphQLRepos[fullName]; ok {
+					res.Data[alias] = graphQLNode(fullName)
+				} else {
+					res.Data[alias] = nil
+					res.Errors = append(res.Errors, graphQLError{
+						Type:    "NOT_FOUND",
+						Path:    []any{alias},
+						Message: fmt.Sprintf("Could not resolve to a Repository with the name '%s'.", fullName),
+					})
+				}
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(res)
+	}))
+	t.Cleanup(srv.Close)
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(srv.URL + "/")
+
+	return &Client{client}
+}
+
+func TestClient_SearchRepositories(t *testing.T) {
+	client := newGraphQLServer(t)
+
+	var (
+		cursor string
+		names  []string
+		pages  int
+	)
+
+	for {
+		page, err := client.SearchRepositories(context.Background(), "language:Python", cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, len(graphQLOrder), page.Total)
+
+		for _, repo := range page.Repositories {
+			info := repo.RepoInfo()
+
+			assert.Equal(t, graphQLRepos[info.FullName()], info.SHA())
+			assert.Equal(t, "master", repo.DefaultBranch())
+
+			stars, _ := info.Meta(MetaStars)
+			license, _ := info.Meta(MetaLicense)
+			topics, _ := info.Meta(MetaTopics)
+			pushedAt, _ := info.Meta(MetaPushedAt)
+
+			assert.Equal(t, len(info.FullName())*10, stars)
+			assert.Equal(t, "MIT", license)
+			assert.Equal(t, []string{"python"}, topics)
+			assert.Equal(t, "2024-12-01T10:00:00Z", pushedAt)
+
+			names = append(names, info.FullName())
+		}
+
+		pages++
+
+		if page.Next == "" {
+			break
+		}
+
+		cursor = page.Next
+	}
+
+	assert.Equal(t, 2, pages)
+	assert.Equal(t, graphQLOrder, names)
+}
+
+func TestClient_LookupRepositories(t *testing.T) {
+	client := newGraphQLServer(t)
+
+	repos, err := client.LookupRepositories(context.Background(), []string{
+		"vinta/awesome-python",
+		"gaarutyunov/does-not-exist",
+		"public-apis/public-apis",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, repos, 2)
+	assert.Equal(t, "git@github.com:vinta/awesome-python.git", repos["vinta/awesome-python"].SSHURL)
+	assert.Equal(t, graphQLRepos["public-apis/public-apis"], repos["public-apis/public-apis"].HeadOID())
+	assert.NotContains(t, repos, "gaarutyunov/does-not-exist")
+}
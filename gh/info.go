@@ -1,17 +1,35 @@
 package gh
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"github.com/spf13/cast"
+	"maps"
 	"strings"
 )
 
+// FormatVersion is the version of the results format written by RepoInfo.String.
+// Version 1 is the legacy headerless `;`-separated line with four positional fields,
+// version 2 is a JSON object per line that can carry arbitrary metadata.
+const FormatVersion = 2
+
 type RepoInfo struct {
 	sshURL   string
 	fullName string
 	repoDir  string
 	sha      string
 	size     uint64
+	meta     map[string]any
+}
+
+type repoInfoJSON struct {
+	Version  int            `json:"v"`
+	FullName string         `json:"full_name"`
+	SshURL   string         `json:"ssh_url"`
+	Size     uint64         `json:"size"`
+	SHA      string         `json:"sha,omitempty"`
+	Meta     map[string]any `json:"meta,omitempty"`
 }
 
 func NewRepoInfo(fullName string, sshURL string, size uint64) RepoInfo {
@@ -29,13 +47,29 @@ func (r RepoInfo) WithSHA(sha string) RepoInfo {
 	return r
 }
 
+// WithMeta returns a copy of the RepoInfo with the metadata value set.
+func (r RepoInfo) WithMeta(key string, value any) RepoInfo {
+	return r.withMetadata(map[string]any{key: value})
+}
+
+// RepoInfoFromString parses a results line in any supported format version.
 func RepoInfoFromString(s string) (repo RepoInfo, err error) {
+	if strings.HasPrefix(strings.TrimSpace(s), "{") {
+		err = repo.UnmarshalJSON([]byte(s))
+		return
+	}
+
 	vals := strings.Split(s, ";")
 	if len(vals) < 3 {
 		err = fmt.Errorf("invalid string format: %s", s)
 		return
 	}
 
+	if err = validateFullName(vals[0]); err != nil {
+		err = fmt.Errorf("%w: %s", err, s)
+		return
+	}
+
 	repo = NewRepoInfo(vals[0], vals[1], cast.ToUint64(vals[2]))
 	if len(vals) > 3 {
 		repo = repo.WithSHA(strings.TrimSpace(vals[3]))
@@ -44,6 +78,16 @@ func RepoInfoFromString(s string) (repo RepoInfo, err error) {
 	return
 }
 
+// validateFullName rejects names that are not owner/name, as Owner and Name expect.
+func validateFullName(fullName string) error {
+	owner, name, ok := strings.Cut(fullName, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid full name %q, expected owner/name", fullName)
+	}
+
+	return nil
+}
+
 func (r RepoInfo) SshURL() string {
 	return r.sshURL
 }
@@ -73,12 +117,88 @@ func (r RepoInfo) Dir() string {
 	return r.repoDir
 }
 
+// Meta returns the metadata value recorded for the key.
+func (r RepoInfo) Meta(key string) (value any, ok bool) {
+	value, ok = r.meta[key]
+
+	return
+}
+
+// Field returns a field or a metadata value by its name in the results format.
+func (r RepoInfo) Field(name string) (value any, ok bool) {
+	switch name {
+	case "full_name":
+		return r.FullName(), true
+	case "owner":
+		return r.Owner(), true
+	case "name":
+		return r.Name(), true
+	case "ssh_url":
+		return r.SshURL(), true
+	case "size":
+		return r.Size(), true
+	case "sha":
+		return r.SHA(), true
+	default:
+		return r.Meta(name)
+	}
+}
+
+// Metadata returns a copy of all recorded metadata.
+func (r RepoInfo) Metadata() map[string]any {
+	return maps.Clone(r.meta)
+}
+
+func (r RepoInfo) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(repoInfoJSON{
+		Version:  FormatVersion,
+		FullName: r.fullName,
+		SshURL:   r.sshURL,
+		Size:     r.size,
+		SHA:      r.sha,
+		Meta:     r.meta,
+	}); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+func (r *RepoInfo) UnmarshalJSON(data []byte) error {
+	var v repoInfoJSON
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("invalid string format: %s: %w", data, err)
+	}
+
+	if v.Version < 2 || v.Version > FormatVersion {
+		return fmt.Errorf("unsupported format version %d: %s", v.Version, data)
+	}
+
+	if err := validateFullName(v.FullName); err != nil {
+		return fmt.Errorf("%w: %s", err, data)
+	}
+
+	*r = NewRepoInfo(v.FullName, v.SshURL, v.Size).WithSHA(v.SHA)
+	r.meta = v.Meta
+
+	return nil
+}
+
+// String formats the RepoInfo as a results line of the current FormatVersion.
 func (r RepoInfo) String() string {
-	return fmt.Sprintf(
-		"%s;%s;%d;%s",
-		r.FullName(),
-		r.SshURL(),
-		r.Size(),
-		r.SHA(),
-	)
+	data, err := r.MarshalJSON()
+	if err != nil {
+		return fmt.Sprintf("%s;%s;%d;%s", r.FullName(), r.SshURL(), r.Size(), r.SHA())
+	}
+
+	return string(data)
 }
@@ -0,0 +1,31 @@
+package gh
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRepoInfoFromString(t *testing.T) {
+	for s, fullName := range map[string]string{
+		`{"v":2,"full_name":"psf/requests","ssh_url":"git@github.com:psf/requests.git","size":1}`: "psf/requests",
+		`psf/requests;git@github.com:psf/requests.git;1;abc`:                                      "psf/requests",
+		`{"v":2,"full_name":"requests","ssh_url":"","size":1}`:                                    "",
+		`{"v":2,"full_name":"psf/","ssh_url":"","size":1}`:                                        "",
+		`{"v":2,"full_name":"/requests","ssh_url":"","size":1}`:                                   "",
+		`{"v":2,"full_name":"psf/requests/x","ssh_url":"","size":1}`:                              "",
+		`{"v":2,"ssh_url":"","size":1}`:                                                           "",
+		`requests;git@github.com:psf/requests.git;1`:                                              "",
+	} {
+		repo, err := RepoInfoFromString(s)
+		if fullName == "" {
+			assert.Error(t, err, s)
+			continue
+		}
+
+		if assert.NoError(t, err, s) {
+			assert.Equal(t, fullName, repo.FullName())
+			assert.Equal(t, "psf", repo.Owner())
+			assert.Equal(t, "requests", repo.Name())
+		}
+	}
+}
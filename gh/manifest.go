@@ -0,0 +1,63 @@
+package gh
+
+import (
+	"encoding/json"
+	"github.com/gaarutyunov/gh-exporter/lang"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ManifestName is the file in the repository directory with the language of every kept file.
+const ManifestName = ".languages.json"
+
+// languageManifest records the detected language of the kept files by their path relative to the repository.
+type languageManifest struct {
+	languages []string
+	files     map[string]string
+}
+
+func newLanguageManifest(languages []string) *languageManifest {
+	if len(languages) == 0 {
+		return nil
+	}
+
+	return &languageManifest{languages: languages, files: make(map[string]string)}
+}
+
+// detect tells whether the file is of one of the languages and records it, nil manifests keep all files.
+func (m *languageManifest) detect(fs billy.Filesystem, path string) (bool, error) {
+	if m == nil {
+		return true, nil
+	}
+
+	fi, err := fs.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer fi.Close()
+
+	language, err := lang.Detect(path, fi)
+	if err != nil || !slices.Contains(m.languages, language) {
+		return false, err
+	}
+
+	m.files[strings.TrimPrefix(filepath.ToSlash(path), "/")] = language
+
+	return true, nil
+}
+
+func (m *languageManifest) write(fs billy.Filesystem) error {
+	if m == nil {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(m.files, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFile(fs, ManifestName, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,68 @@
+package gh
+
+import (
+	"github.com/google/go-github/v45/github"
+	"maps"
+	"time"
+)
+
+// Metadata keys recorded in the results file.
+const (
+	MetaStars         = "stars"
+	MetaForks         = "forks"
+	MetaLanguage      = "language"
+	MetaLicense       = "license"
+	MetaTopics        = "topics"
+	MetaArchived      = "archived"
+	MetaFork          = "fork"
+	MetaTemplate      = "template"
+	MetaPushedAt      = "pushed_at"
+	MetaDefaultBranch = "default_branch"
+	// MetaParent is the full name of the repository a fork was created from.
+	MetaParent = "parent"
+	// MetaRootSHA is the SHA of the first commit on the default branch, shared by forks and mirrors.
+	MetaRootSHA = "root_sha"
+)
+
+// RepoInfoFromRepository converts the REST API repository to RepoInfo with its metadata recorded.
+func RepoInfoFromRepository(repo *github.Repository) RepoInfo {
+	info := NewRepoInfo(repo.GetFullName(), repo.GetSSHURL(), uint64(repo.GetSize()))
+
+	topics := repo.Topics
+	if topics == nil {
+		topics = []string{}
+	}
+
+	var pushedAt string
+	if repo.PushedAt != nil {
+		pushedAt = repo.GetPushedAt().UTC().Format(time.RFC3339)
+	}
+
+	if parent := repo.GetParent().GetFullName(); parent != "" {
+		info = info.WithMeta(MetaParent, parent)
+	}
+
+	return info.withMetadata(map[string]any{
+		MetaStars:         repo.GetStargazersCount(),
+		MetaForks:         repo.GetForksCount(),
+		MetaLanguage:      repo.GetLanguage(),
+		MetaLicense:       repo.GetLicense().GetSPDXID(),
+		MetaTopics:        topics,
+		MetaArchived:      repo.GetArchived(),
+		MetaFork:          repo.GetFork(),
+		MetaTemplate:      repo.GetIsTemplate(),
+		MetaPushedAt:      pushedAt,
+		MetaDefaultBranch: repo.GetDefaultBranch(),
+	})
+}
+
+func (r RepoInfo) withMetadata(meta map[string]any) RepoInfo {
+	r.meta = maps.Clone(r.meta)
+	if r.meta == nil {
+		r.meta = make(map[string]any, len(meta))
+	}
+
+	maps.Copy(r.meta, meta)
+
+	return r
+}
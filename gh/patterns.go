@@ -0,0 +1,62 @@
+package gh
+
+import (
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"path/filepath"
+	"strings"
+)
+
+// Patterns select the files kept after cloning using gitignore syntax.
+// Patterns without a slash match file or directory names at any depth, patterns with a slash are anchored
+// to the repository root, `**` matches any number of directories and a trailing slash matches only directories.
+// As in a sparse checkout file, a matching pattern keeps a file, a pattern starting with `!` drops it
+// and the last matching pattern wins.
+type Patterns struct {
+	matcher gitignore.Matcher
+}
+
+// NewPatterns keeps files matching any of the include patterns and none of the exclude patterns.
+func NewPatterns(include, exclude []string) (*Patterns, error) {
+	ps := make([]gitignore.Pattern, 0, len(include)+len(exclude))
+
+	for _, p := range include {
+		if err := validatePattern(p); err != nil {
+			return nil, err
+		}
+
+		ps = append(ps, gitignore.ParsePattern(p, nil))
+	}
+
+	// excludes are added last so that they take precedence over includes
+	for _, p := range exclude {
+		if err := validatePattern(p); err != nil {
+			return nil, err
+		}
+
+		ps = append(ps, gitignore.ParsePattern("!"+p, nil))
+	}
+
+	return &Patterns{matcher: gitignore.NewMatcher(ps)}, nil
+}
+
+// validatePattern reports malformed patterns that gitignore matching silently treats as not matching.
+func validatePattern(p string) error {
+	for _, part := range strings.Split(strings.TrimPrefix(p, "!"), "/") {
+		if _, err := filepath.Match(part, ""); err != nil {
+			return fmt.Errorf("%w: %s", err, p)
+		}
+	}
+
+	return nil
+}
+
+// Match tells whether the file at the slash separated path relative to the repository root is kept.
+// Nil patterns keep all files.
+func (p *Patterns) Match(path string) bool {
+	if p == nil {
+		return true
+	}
+
+	return p.matcher.Match(strings.Split(strings.Trim(filepath.ToSlash(path), "/"), "/"), false)
+}
@@ -0,0 +1,40 @@
+package gh
+
+import (
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func TestPatterns_Match(t *testing.T) {
+	patterns, err := NewPatterns(
+		[]string{"*.py", "*.pyi", "/scripts/**/*.sh"},
+		[]string{"tests/", "/vendor/", "**/migrations/*.py", "conftest.py"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, expected := range map[string]bool{
+		"/setup.py":                    true,
+		"/pkg/mod.pyi":                 true,
+		"/pkg/README.md":               false,
+		"/pkg/tests/test_mod.py":       false,
+		"/tests/test_mod.py":           false,
+		"/vendor/six.py":               false,
+		"/pkg/vendor/six.py":           true,
+		"/app/migrations/0001_init.py": false,
+		"/app/migrations/sub/x.py":     true,
+		"/conftest.py":                 false,
+		"/scripts/ci/build.sh":         true,
+		"/tools/scripts/build.sh":      false,
+	} {
+		assert.Equal(t, expected, patterns.Match(path), path)
+	}
+
+	var nilPatterns *Patterns
+	assert.True(t, nilPatterns.Match("/any.txt"))
+
+	_, err = NewPatterns([]string{"[a-"}, nil)
+	assert.ErrorIs(t, err, filepath.ErrBadPattern)
+}
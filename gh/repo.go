@@ -2,22 +2,25 @@ package gh
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/helper/chroot"
 	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-billy/v5/util"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/cache"
-	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/storage"
 	"github.com/go-git/go-git/v5/storage/filesystem"
 	"github.com/go-git/go-git/v5/storage/memory"
 	"github.com/google/go-github/v45/github"
-	"github.com/sirupsen/logrus"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 type Repo struct {
@@ -59,36 +62,157 @@ func (r *Repo) GetDefaultBranch() string {
 	return branch
 }
 
-func (r *Repo) CloneFS(ctx context.Context, sshKey *ssh.PublicKeys, pattern string, outFs billy.Filesystem) error {
-	outFs = chroot.New(outFs, r.repoDir)
+// exportRef is the branch the planned commit is fetched to.
+const exportRef = "refs/heads/gh-exporter"
+
+// CloneOptions configure how repositories are cloned and which files are kept.
+type CloneOptions struct {
+	Transport Transport
+	// Patterns select the files that are kept, all files are kept if nil.
+	Patterns *Patterns
+	// Content drops kept files by their size and content.
+	Content ContentFilter
+	// Languages keep only files detected as one of the languages and record them in the manifest, if not empty.
+	Languages []string
+	// Depth limits fetched history to the number of commits, 0 fetches full history.
+	Depth int
+	// SingleBranch fetches only the default branch without tags.
+	SingleBranch bool
+	// Exact initializes an empty repository and fetches only the planned commit instead of cloning and resetting to it.
+	Exact bool
+}
 
-	dot, err := outFs.Chroot(git.GitDirName)
-	if err != nil {
-		return err
+func (r *Repo) cloneOptions(opts CloneOptions) *git.CloneOptions {
+	cloneOpts := &git.CloneOptions{
+		Auth:         opts.Transport.Auth,
+		URL:          opts.Transport.URL(r.RepoInfo),
+		Depth:        opts.Depth,
+		SingleBranch: opts.SingleBranch,
 	}
 
-	rr, err := git.CloneContext(ctx, filesystem.NewStorage(dot, cache.NewObjectLRU(128*cache.MiByte)), outFs, &git.CloneOptions{
-		Auth: sshKey,
-		URL:  r.sshURL,
-	})
+	if opts.SingleBranch {
+		cloneOpts.Tags = git.NoTags
+	}
+
+	return cloneOpts
+}
+
+// checkout clones the repository into the storage and the worktree and checks out the planned commit.
+// It returns the checked out commit, the planned commit that can't be checked out is an error.
+func (r *Repo) checkout(ctx context.Context, s storage.Storer, worktree billy.Filesystem, opts CloneOptions) (string, error) {
+	if opts.Exact && r.sha != "" {
+		return r.fetchExact(ctx, s, worktree, opts)
+	}
+
+	rr, err := git.CloneContext(ctx, s, worktree, r.cloneOptions(opts))
 	if err != nil {
-		return err
+		return "", withStage(StageClone, err)
 	}
 
 	if r.sha != "" {
-		if w, err := rr.Worktree(); err != nil {
-			return err
-		} else {
-			err := w.Reset(&git.ResetOptions{
-				Commit: plumbing.NewHash(r.sha),
-				Mode:   git.HardReset,
-			})
-			if err != nil {
-				logrus.Errorf("error resetting %s to %s: %s", r.FullName(), r.SHA(), err)
-			}
+		w, err := rr.Worktree()
+		if err != nil {
+			return "", withStage(StageCheckout, err)
+		}
+
+		err = w.Reset(&git.ResetOptions{
+			Commit: plumbing.NewHash(r.sha),
+			Mode:   git.HardReset,
+		})
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			// the commit may be older than the fetched history or on another branch
+			return r.fetchCommit(ctx, rr, opts)
+		}
+		if err != nil {
+			return "", withStage(StageCheckout, err)
+		}
+
+		return r.sha, nil
+	}
+
+	head, err := rr.Head()
+	if err != nil {
+		return "", withStage(StageCheckout, err)
+	}
+
+	return head.Hash().String(), nil
+}
+
+// fetchExact initializes an empty repository, fetches the planned commit without history and checks it out.
+func (r *Repo) fetchExact(ctx context.Context, s storage.Storer, worktree billy.Filesystem, opts CloneOptions) (string, error) {
+	rr, err := git.Init(s, worktree)
+	if err != nil {
+		return "", withStage(StageClone, err)
+	}
+
+	if _, err = rr.CreateRemote(&config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{opts.Transport.URL(r.RepoInfo)},
+	}); err != nil {
+		return "", withStage(StageClone, err)
+	}
+
+	return r.fetchCommit(ctx, rr, opts)
+}
+
+// fetchCommit fetches the planned commit without history from the origin remote and checks it out.
+func (r *Repo) fetchCommit(ctx context.Context, rr *git.Repository, opts CloneOptions) (string, error) {
+	remote, err := rr.Remote(git.DefaultRemoteName)
+	if err != nil {
+		return "", withStage(StageClone, err)
+	}
+
+	err = remote.FetchContext(ctx, &git.FetchOptions{
+		Auth:     opts.Transport.Auth,
+		RefSpecs: []config.RefSpec{config.RefSpec(r.sha + ":" + exportRef)},
+		Depth:    1,
+		Tags:     git.NoTags,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "not our ref") {
+			err = fmt.Errorf("%w: %s: %w", ErrCommitUnreachable, r.sha, err)
 		}
+
+		return "", withStage(StageClone, err)
+	}
+
+	w, err := rr.Worktree()
+	if err != nil {
+		return "", withStage(StageCheckout, err)
+	}
+
+	err = w.Checkout(&git.CheckoutOptions{Hash: plumbing.NewHash(r.sha), Force: true})
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		err = fmt.Errorf("%w: %s: %w", ErrCommitUnreachable, r.sha, err)
+	}
+	if err != nil {
+		return "", withStage(StageCheckout, err)
+	}
+
+	return r.sha, nil
+}
+
+// CloneStats describes the checked out revision and the files kept after cloning.
+type CloneStats struct {
+	SHA   string
+	Files int
+	Bytes int64
+}
+
+func (r *Repo) CloneFS(ctx context.Context, opts CloneOptions, outFs billy.Filesystem) (stats CloneStats, err error) {
+	outFs = chroot.New(outFs, r.repoDir)
+
+	dot, err := outFs.Chroot(git.GitDirName)
+	if err != nil {
+		return stats, withStage(StageClone, err)
 	}
 
+	if stats.SHA, err = r.checkout(ctx, filesystem.NewStorage(dot, cache.NewObjectLRU(128*cache.MiByte)), outFs, opts); err != nil {
+		return
+	}
+
+	manifest := newLanguageManifest(opts.Languages)
+
 	err = util.Walk(outFs, "/", func(path string, info fs.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -100,54 +224,56 @@ func (r *Repo) CloneFS(ctx context.Context, sshKey *ssh.PublicKeys, pattern stri
 		default:
 		}
 
+		// git metadata is neither filtered nor counted as exported files, it is removed after the walk
+		if info.IsDir() && info.Name() == git.GitDirName {
+			return filepath.SkipDir
+		}
+
 		if info.IsDir() || info.Mode()&fs.ModeSymlink != 0 {
 			return nil
 		}
 
-		if match, err := filepath.Match(pattern, info.Name()); err != nil {
+		if !opts.Patterns.Match(path) {
+			return outFs.Remove(path)
+		}
+
+		if keep, err := opts.Content.Keep(outFs, path, info); err != nil {
 			return err
-		} else if !match {
-			if err := outFs.Remove(path); err != nil {
-				return err
-			}
+		} else if !keep {
+			return outFs.Remove(path)
 		}
 
+		if keep, err := manifest.detect(outFs, path); err != nil {
+			return err
+		} else if !keep {
+			return outFs.Remove(path)
+		}
+
+		stats.Files++
+		stats.Bytes += info.Size()
+
 		return nil
 	})
-
-	if err != nil {
-		return err
+	if err == nil {
+		err = util.RemoveAll(outFs, git.GitDirName)
+	}
+	if err == nil {
+		err = manifest.write(outFs)
 	}
 
-	return nil
+	return stats, withStage(StageFilter, err)
 }
 
-func (r *Repo) CloneMem(ctx context.Context, sshKey *ssh.PublicKeys, pattern string, outFs billy.Filesystem) error {
+func (r *Repo) CloneMem(ctx context.Context, opts CloneOptions, outFs billy.Filesystem) (stats CloneStats, err error) {
 	memFs := memfs.New()
 	outFs = chroot.New(outFs, r.repoDir)
 	storage := memory.NewStorage()
 
-	rr, err := git.CloneContext(ctx, storage, memFs, &git.CloneOptions{
-		Auth: sshKey,
-		URL:  r.sshURL,
-	})
-	if err != nil {
-		return err
+	if stats.SHA, err = r.checkout(ctx, storage, memFs, opts); err != nil {
+		return
 	}
 
-	if r.sha != "" {
-		if w, err := rr.Worktree(); err != nil {
-			return err
-		} else {
-			err := w.Reset(&git.ResetOptions{
-				Commit: plumbing.NewHash(r.sha),
-				Mode:   git.HardReset,
-			})
-			if err != nil {
-				logrus.Errorf("error resetting %s to %s: %s", r.FullName(), r.SHA(), err)
-			}
-		}
-	}
+	manifest := newLanguageManifest(opts.Languages)
 
 	err = util.Walk(memFs, memFs.Root(), func(path string, info fs.FileInfo, err error) error {
 		if err != nil {
@@ -164,12 +290,18 @@ func (r *Repo) CloneMem(ctx context.Context, sshKey *ssh.PublicKeys, pattern str
 			return nil
 		}
 
-		if match, err := filepath.Match(pattern, info.Name()); err != nil {
-			return err
-		} else if !match {
+		if !opts.Patterns.Match(path) {
 			return nil
 		}
 
+		if keep, err := opts.Content.Keep(memFs, path, info); err != nil || !keep {
+			return err
+		}
+
+		if keep, err := manifest.detect(memFs, path); err != nil || !keep {
+			return err
+		}
+
 		src, err := memFs.Open(path)
 		if err != nil {
 			return err
@@ -185,15 +317,18 @@ func (r *Repo) CloneMem(ctx context.Context, sshKey *ssh.PublicKeys, pattern str
 			_ = dst.Close()
 		}()
 
-		_, err = io.Copy(dst, src)
+		n, err := io.Copy(dst, src)
+
+		stats.Files++
+		stats.Bytes += n
 
 		return err
 	})
-	if err != nil {
-		return err
+	if err == nil {
+		err = manifest.write(outFs)
 	}
 
-	return nil
+	return stats, withStage(StageFilter, err)
 }
 
 func (r *Repo) Exists(fs billy.Filesystem) (bool, error) {
@@ -207,3 +342,8 @@ func (r *Repo) Exists(fs billy.Filesystem) (bool, error) {
 
 	return true, nil
 }
+
+// Remove deletes the cloned repository, e.g. left after an interrupted export.
+func (r *Repo) Remove(fs billy.Filesystem) error {
+	return util.RemoveAll(fs, r.repoDir)
+}
@@ -0,0 +1,321 @@
+package gh
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newRemote creates the repository o/r with a commit per set of files served over file:// and returns commit hashes.
+func newRemote(t *testing.T, commits ...map[string]string) []plumbing.Hash {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "o", "r.git")
+
+	repo, err := git.PlainInit(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// as on GitHub, any reachable commit can be fetched
+	cfg.Raw.Section("uploadpack").SetOption("allowReachableSHA1InWant", "true")
+
+	if err := repo.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hashes []plumbing.Hash
+
+	for i, files := range commits {
+		for name, content := range files {
+			if err := os.MkdirAll(filepath.Dir(filepath.Join(path, name)), 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := os.WriteFile(filepath.Join(path, name), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := w.Add(name); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		hash, err := w.Commit("commit", &git.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Unix(int64(i), 0)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		hashes = append(hashes, hash)
+	}
+
+	baseURL := HTTPSBaseURL
+	HTTPSBaseURL = "file://" + dir + "/"
+	t.Cleanup(func() { HTTPSBaseURL = baseURL })
+
+	return hashes
+}
+
+func TestRepo_CloneFS(t *testing.T) {
+	hashes := newRemote(t,
+		map[string]string{"a.py": "a", "b.txt": "b"},
+		map[string]string{"c.py": "c"},
+	)
+
+	patterns, err := NewPatterns([]string{"*.py"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, depth := range []int{0, 1} {
+		out := t.TempDir()
+		repo := NewRepo(NewRepoInfo("o/r", "", 1), nil)
+
+		stats, err := repo.CloneFS(context.Background(), CloneOptions{
+			Transport:    NewAnonymousTransport(),
+			Patterns:     patterns,
+			Depth:        depth,
+			SingleBranch: true,
+		}, osfs.New(out))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, hashes[1].String(), stats.SHA)
+		assert.Equal(t, 2, stats.Files)
+		assert.FileExists(t, filepath.Join(out, "o.r", "c.py"))
+		assert.NoFileExists(t, filepath.Join(out, "o.r", "b.txt"))
+		assert.NoDirExists(t, filepath.Join(out, "o.r", git.GitDirName))
+	}
+}
+
+func TestRepo_Filters(t *testing.T) {
+	newRemote(t, map[string]string{
+		"setup.py":             "",
+		"pkg/a.py":             "",
+		"pkg/a.pyi":            "",
+		"pkg/README.md":        "",
+		"pkg/tests/test_a.py":  "",
+		"vendor/six.py":        "",
+		"docs/vendor/conf.py":  "",
+		"docs/examples/ex.txt": "",
+		"pkg/a_pb2.py":         "# Generated by the protocol buffer compiler.  DO NOT EDIT!\n",
+		"pkg/blob.py":          "\x00",
+		"pkg/big.py":           strings.Repeat("x", 1024),
+	})
+
+	patterns, err := NewPatterns([]string{"*.py", "*.pyi"}, []string{"tests/", "/vendor/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"docs/vendor/conf.py", "pkg/a.py", "pkg/a.pyi", "setup.py"}
+
+	for _, clone := range []func(*Repo, context.Context, CloneOptions, billy.Filesystem) (CloneStats, error){
+		(*Repo).CloneFS,
+		(*Repo).CloneMem,
+	} {
+		out := t.TempDir()
+		repo := NewRepo(NewRepoInfo("o/r", "", 1), nil)
+
+		stats, err := clone(repo, context.Background(), CloneOptions{
+			Transport: NewAnonymousTransport(),
+			Patterns:  patterns,
+			Content:   ContentFilter{MaxSize: 512, Binary: true, Generated: true},
+		}, osfs.New(out))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var files []string
+
+		err = filepath.WalkDir(filepath.Join(out, "o.r"), func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+
+			rel, err := filepath.Rel(filepath.Join(out, "o.r"), path)
+			files = append(files, filepath.ToSlash(rel))
+
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, expected, files)
+		assert.Equal(t, len(expected), stats.Files)
+	}
+}
+
+func TestRepo_Languages(t *testing.T) {
+	newRemote(t, map[string]string{
+		"setup.py":         "",
+		"bin/manage":       "#!/usr/bin/env python3\n",
+		"bin/deploy":       "#!/bin/sh\n",
+		"gui.pyw":          "",
+		"main.go":          "package main\n",
+		"web/app.js":       "",
+		"analysis.ipynb":   `{"cells": [], "metadata": {"kernelspec": {"language": "python"}}}`,
+		"docs/index.md":    "",
+		"scripts/setup.sh": "",
+	})
+
+	patterns, err := NewPatterns([]string{"*"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"analysis.ipynb": "Python",
+		"bin/manage":     "Python",
+		"gui.pyw":        "Python",
+		"main.go":        "Go",
+		"setup.py":       "Python",
+	}
+
+	for _, clone := range []func(*Repo, context.Context, CloneOptions, billy.Filesystem) (CloneStats, error){
+		(*Repo).CloneFS,
+		(*Repo).CloneMem,
+	} {
+		out := t.TempDir()
+		repo := NewRepo(NewRepoInfo("o/r", "", 1), nil)
+
+		stats, err := clone(repo, context.Background(), CloneOptions{
+			Transport: NewAnonymousTransport(),
+			Patterns:  patterns,
+			Languages: []string{"Python", "Go"},
+		}, osfs.New(out))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(filepath.Join(out, "o.r", ManifestName))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var manifest map[string]string
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, expected, manifest)
+		assert.Equal(t, len(expected), stats.Files)
+		assert.NoFileExists(t, filepath.Join(out, "o.r", "web", "app.js"))
+		assert.NoFileExists(t, filepath.Join(out, "o.r", "bin", "deploy"))
+	}
+}
+
+func TestRepo_cloneOptions(t *testing.T) {
+	repo := NewRepo(NewRepoInfo("o/r", "git@github.com:o/r.git", 1), nil)
+
+	opts := repo.cloneOptions(CloneOptions{Transport: NewSSHTransport(nil), Depth: 1, SingleBranch: true})
+
+	assert.Equal(t, "git@github.com:o/r.git", opts.URL)
+	assert.Equal(t, 1, opts.Depth)
+	assert.True(t, opts.SingleBranch)
+	assert.Equal(t, git.NoTags, opts.Tags)
+
+	opts = repo.cloneOptions(CloneOptions{Transport: NewSSHTransport(nil)})
+
+	assert.Equal(t, 0, opts.Depth)
+	assert.False(t, opts.SingleBranch)
+	assert.Equal(t, git.InvalidTagMode, opts.Tags)
+}
+
+func TestRepo_CloneMem_Exact(t *testing.T) {
+	hashes := newRemote(t,
+		map[string]string{"a.py": "a"},
+		map[string]string{"c.py": "c"},
+	)
+
+	for _, exact := range []bool{false, true} {
+		for _, hash := range hashes {
+			repo := NewRepo(NewRepoInfo("o/r", "", 1).WithSHA(hash.String()), nil)
+
+			stats, err := repo.CloneMem(context.Background(), CloneOptions{
+				Transport:    NewAnonymousTransport(),
+				SingleBranch: true,
+				Exact:        exact,
+			}, osfs.New(t.TempDir()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, hash.String(), stats.SHA)
+		}
+
+		repo := NewRepo(NewRepoInfo("o/r", "", 1).WithSHA("1111111111111111111111111111111111111111"), nil)
+
+		_, err := repo.CloneMem(context.Background(), CloneOptions{
+			Transport: NewAnonymousTransport(),
+			Exact:     exact,
+		}, osfs.New(t.TempDir()))
+
+		assert.Equal(t, StageClone, StageOf(err, StageFilter))
+
+		// the local upload-pack may close the connection before its error is read
+		if !strings.Contains(err.Error(), "upload-pack") {
+			assert.Error(t, err)
+			continue
+		}
+
+		assert.ErrorIs(t, err, ErrCommitUnreachable)
+		assert.Equal(t, ClassUnreachable, Classify(err))
+		assert.False(t, IsRetryable(err))
+	}
+}
+
+func TestRepo_Clone_OlderThanDepth(t *testing.T) {
+	hashes := newRemote(t,
+		map[string]string{"a.py": "a"},
+		map[string]string{"c.py": "c"},
+	)
+
+	// the default export flags fetch only the head commit, so the planned one is fetched separately
+	opts := CloneOptions{
+		Transport:    NewAnonymousTransport(),
+		Depth:        Depth,
+		SingleBranch: true,
+	}
+
+	for name, clone := range map[string]func(*Repo, context.Context, CloneOptions, billy.Filesystem) (CloneStats, error){
+		"fs":  (*Repo).CloneFS,
+		"mem": (*Repo).CloneMem,
+	} {
+		out := t.TempDir()
+		repo := NewRepo(NewRepoInfo("o/r", "", 1).WithSHA(hashes[0].String()), nil)
+
+		stats, err := clone(repo, context.Background(), opts, osfs.New(out))
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		assert.Equal(t, hashes[0].String(), stats.SHA, name)
+		assert.FileExists(t, filepath.Join(out, "o.r", "a.py"), name)
+		assert.NoFileExists(t, filepath.Join(out, "o.r", "c.py"), name)
+	}
+}
@@ -0,0 +1,72 @@
+package gh
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	cryptossh "golang.org/x/crypto/ssh"
+	"os"
+)
+
+const sshUser = "git"
+
+// SSHOptions configure authentication and host verification for SSH cloning.
+type SSHOptions struct {
+	// Identity is the private key path, not used with Agent.
+	Identity string
+	// Agent authenticates with the keys of a running ssh-agent.
+	Agent bool
+	// Passphrase returns the passphrase of the key, it is only called if the key is encrypted.
+	Passphrase func() (string, error)
+	// KnownHosts are the files with trusted host keys, go-git defaults are used if empty.
+	// Unknown hosts and changed host keys are rejected.
+	KnownHosts []string
+}
+
+func NewSSHAuth(opts SSHOptions) (transport.AuthMethod, error) {
+	for _, path := range opts.KnownHosts {
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("known hosts: %w", err)
+		}
+	}
+
+	hostKeyCallback, err := ssh.NewKnownHostsCallback(opts.KnownHosts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Agent {
+		auth, err := ssh.NewSSHAgentAuth(sshUser)
+		if err != nil {
+			return nil, fmt.Errorf("ssh-agent: %w", err)
+		}
+
+		auth.HostKeyCallback = hostKeyCallback
+
+		return auth, nil
+	}
+
+	pem, err := os.ReadFile(opts.Identity)
+	if err != nil {
+		return nil, err
+	}
+
+	var passphrase string
+
+	var missingErr *cryptossh.PassphraseMissingError
+	if _, err := cryptossh.ParsePrivateKey(pem); errors.As(err, &missingErr) && opts.Passphrase != nil {
+		if passphrase, err = opts.Passphrase(); err != nil {
+			return nil, err
+		}
+	}
+
+	auth, err := ssh.NewPublicKeys(sshUser, pem, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", opts.Identity, err)
+	}
+
+	auth.HostKeyCallback = hostKeyCallback
+
+	return auth, nil
+}
@@ -0,0 +1,71 @@
+package gh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/stretchr/testify/assert"
+	cryptossh "golang.org/x/crypto/ssh"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeKey(t *testing.T, dir, passphrase string) string {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := cryptossh.MarshalPrivateKeyWithPassphrase(key, "", []byte(passphrase))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "id_ed25519")
+
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestNewSSHAuth(t *testing.T) {
+	dir := t.TempDir()
+	identity := writeKey(t, dir, "secret")
+	knownHosts := filepath.Join(dir, "known_hosts")
+
+	err := os.WriteFile(knownHosts, []byte("github.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var prompted int
+
+	opts := SSHOptions{
+		Identity: identity,
+		Passphrase: func() (string, error) {
+			prompted++
+			return "secret", nil
+		},
+		KnownHosts: []string{knownHosts},
+	}
+
+	auth, err := NewSSHAuth(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, prompted)
+	assert.NotNil(t, auth.(*ssh.PublicKeys).HostKeyCallback)
+
+	opts.Passphrase = nil
+	_, err = NewSSHAuth(opts)
+	assert.Error(t, err)
+
+	opts.KnownHosts = []string{filepath.Join(dir, "missing")}
+	_, err = NewSSHAuth(opts)
+	assert.ErrorContains(t, err, "known hosts")
+}
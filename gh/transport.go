@@ -0,0 +1,55 @@
+package gh
+
+import (
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+const (
+	TransportSSH       = "ssh"
+	TransportHTTPS     = "https"
+	TransportAnonymous = "anonymous"
+)
+
+// HTTPSBaseURL is the prefix of HTTPS clone URLs.
+var HTTPSBaseURL = "https://github.com/"
+
+// tokenUser is the user name GitHub expects for tokens in basic auth.
+const tokenUser = "x-access-token"
+
+// Transport is the protocol and authentication used to clone repositories.
+type Transport struct {
+	Auth transport.AuthMethod
+	// HTTPS clones from the URL built from the full name instead of the SSH URL.
+	HTTPS bool
+}
+
+func NewSSHTransport(auth transport.AuthMethod) Transport {
+	return Transport{Auth: auth}
+}
+
+// NewTokenTransport clones over HTTPS authenticating with the token.
+func NewTokenTransport(token string) (Transport, error) {
+	if token == "" {
+		return Transport{}, fmt.Errorf("a token is required for the %s transport, set GITHUB_TOKEN", TransportHTTPS)
+	}
+
+	return Transport{
+		Auth:  &http.BasicAuth{Username: tokenUser, Password: token},
+		HTTPS: true,
+	}, nil
+}
+
+// NewAnonymousTransport clones public repositories over HTTPS without authentication.
+func NewAnonymousTransport() Transport {
+	return Transport{HTTPS: true}
+}
+
+func (t Transport) URL(repo RepoInfo) string {
+	if t.HTTPS {
+		return HTTPSBaseURL + repo.FullName() + ".git"
+	}
+
+	return repo.SshURL()
+}
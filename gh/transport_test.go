@@ -0,0 +1,25 @@
+package gh
+
+import (
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTransport_URL(t *testing.T) {
+	repo := NewRepoInfo("vinta/awesome-python", "git@github.com:vinta/awesome-python.git", 1)
+
+	assert.Equal(t, "git@github.com:vinta/awesome-python.git", NewSSHTransport(nil).URL(repo))
+	assert.Equal(t, "https://github.com/vinta/awesome-python.git", NewAnonymousTransport().URL(repo))
+
+	tr, err := NewTokenTransport("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "https://github.com/vinta/awesome-python.git", tr.URL(repo))
+	assert.Equal(t, &http.BasicAuth{Username: "x-access-token", Password: "secret"}, tr.Auth)
+
+	_, err = NewTokenTransport("")
+	assert.Error(t, err)
+}
@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"github.com/gaarutyunov/gh-exporter/gh"
+	"github.com/google/go-github/v45/github"
+	"strconv"
+)
+
+// searchBackend fetches search results page by page.
+// Cursors are opaque strings, an empty cursor points to the first page.
+type searchBackend interface {
+	// Page returns repositories on the page at cursor and the cursor of the next page, empty if it was the last one.
+	Page(ctx context.Context, query string, cursor string, perPage int) (repos []*gh.Repo, next string, err error)
+	// Resolve fills in the head commit SHA of the repository.
+	Resolve(ctx context.Context, repo *gh.Repo) error
+}
+
+func newSearchBackend(name string, client *gh.Client, burst int) (searchBackend, error) {
+	switch name {
+	case "rest":
+		return restBackend{
+			client: client,
+			searchLimiter: gh.NewLimiter(
+				client,
+				gh.WithLimit(gh.SearchLimit),
+				gh.WithBurst(burst),
+			),
+			coreLimiter: gh.NewLimiter(
+				client,
+				gh.WithLimit(gh.CoreLimit),
+				gh.WithBurst(burst),
+			),
+		}, nil
+	case "graphql":
+		return graphQLBackend{
+			client: client,
+			limiter: gh.NewLimiter(
+				client,
+				gh.WithLimit(gh.GraphQLLimit),
+				gh.WithBurst(burst),
+			),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown backend: %s", name)
+	}
+}
+
+type restBackend struct {
+	client        *gh.Client
+	searchLimiter *gh.Limiter
+	coreLimiter   *gh.Limiter
+}
+
+func (b restBackend) Page(ctx context.Context, query string, cursor string, perPage int) (repos []*gh.Repo, next string, err error) {
+	page := 1
+
+	if cursor != "" {
+		if page, err = strconv.Atoi(cursor); err != nil {
+			return nil, "", fmt.Errorf("invalid page cursor %q: %w", cursor, err)
+		}
+	}
+
+	if (page-1)*perPage >= searchCap {
+		return nil, "", nil
+	}
+
+	if err = b.searchLimiter.Wait(ctx); err != nil {
+		return
+	}
+
+	res, _, err := b.client.Search.Repositories(ctx, query, &github.SearchOptions{
+		TextMatch: false,
+		ListOptions: github.ListOptions{
+			Page:    page,
+			PerPage: perPage,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	for _, repository := range res.Repositories {
+		repos = append(repos, gh.NewRepo(gh.RepoInfoFromRepository(repository), repository))
+	}
+
+	if len(repos) > 0 && page*perPage < searchCap {
+		next = strconv.Itoa(page + 1)
+	}
+
+	return
+}
+
+func (b restBackend) Resolve(ctx context.Context, repo *gh.Repo) error {
+	if err := b.coreLimiter.Wait(ctx); err != nil {
+		return err
+	}
+
+	commits, _, err := b.client.Repositories.ListCommits(ctx, repo.Owner(), repo.Name(), &github.CommitsListOptions{
+		SHA: repo.GetDefaultBranch(),
+		ListOptions: github.ListOptions{
+			Page:    0,
+			PerPage: 1,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("list commits: %w", err)
+	}
+
+	if len(commits) > 0 {
+		repo.SetSHA(commits[0].GetSHA())
+	}
+
+	return nil
+}
+
+type graphQLBackend struct {
+	client  *gh.Client
+	limiter *gh.Limiter
+}
+
+func (b graphQLBackend) Page(ctx context.Context, query string, cursor string, perPage int) (repos []*gh.Repo, next string, err error) {
+	if err = b.limiter.Wait(ctx); err != nil {
+		return
+	}
+
+	page, err := b.client.SearchRepositories(ctx, query, cursor, perPage)
+	if err != nil {
+		return
+	}
+
+	for _, repository := range page.Repositories {
+		repos = append(repos, gh.NewRepo(repository.RepoInfo(), nil))
+	}
+
+	return repos, page.Next, nil
+}
+
+// Resolve is a no-op, head commit is fetched together with the search results.
+func (b graphQLBackend) Resolve(context.Context, *gh.Repo) error {
+	return nil
+}
@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const checkpointExt = ".checkpoint"
+
+// searchCheckpoint is the persisted progress of a search run.
+// Slice and Cursor point to the last page whose repositories were (at least partially) written to the results file,
+// so resuming starts from it again and relies on already emitted names being skipped.
+type searchCheckpoint struct {
+	Query   string   `json:"query"`
+	Slices  []string `json:"slices"`
+	Slice   int      `json:"slice"`
+	Cursor  string   `json:"cursor"`
+	PerPage int      `json:"per_page"`
+	Emitted int64    `json:"emitted"`
+}
+
+func checkpointPath(out string) string {
+	return out + checkpointExt
+}
+
+func loadCheckpoint(path string) (cp searchCheckpoint, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	if err = json.Unmarshal(data, &cp); err != nil {
+		err = fmt.Errorf("invalid checkpoint %s: %w", path, err)
+	}
+
+	return
+}
+
+func (cp searchCheckpoint) save(path string) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
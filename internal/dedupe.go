@@ -0,0 +1,247 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/cheggaaa/pb/v3"
+	"github.com/gaarutyunov/gh-exporter/dedupe"
+	"github.com/gaarutyunov/gh-exporter/gh"
+	"github.com/gaarutyunov/gh-exporter/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+)
+
+type dropRecord struct {
+	FullName string        `json:"full_name"`
+	Reason   dedupe.Reason `json:"reason"`
+	Kept     string        `json:"kept"`
+}
+
+func Dedupe(cmd *cobra.Command, args []string) error {
+	in, err := cmd.PersistentFlags().GetStringSlice("in")
+	if err != nil {
+		return err
+	}
+
+	out, err := cmd.PersistentFlags().GetString("out")
+	if err != nil {
+		return err
+	}
+	out = utils.ExpandPath(out)
+
+	report, err := cmd.PersistentFlags().GetString("report")
+	if err != nil {
+		return err
+	}
+	report = utils.ExpandPath(report)
+
+	var opts dedupe.Options
+
+	if opts.Forks, err = cmd.PersistentFlags().GetBool("forks"); err != nil {
+		return err
+	}
+
+	if opts.Mirrors, err = cmd.PersistentFlags().GetBool("mirrors"); err != nil {
+		return err
+	}
+
+	resolve, err := cmd.PersistentFlags().GetBool("resolve")
+	if err != nil {
+		return err
+	}
+
+	concurrency, err := cmd.PersistentFlags().GetInt("concurrency")
+	if err != nil {
+		return err
+	}
+
+	var repos []gh.RepoInfo
+
+	for _, path := range in {
+		results, err := readResults(utils.ExpandPath(path))
+		if err != nil {
+			return err
+		}
+
+		repos = append(repos, results...)
+	}
+
+	repos, dropped := dedupe.Dedupe(repos, dedupe.Options{})
+
+	if resolve && (opts.Forks || opts.Mirrors) {
+		if err := resolveLineage(cmd, repos, opts, concurrency); err != nil {
+			return err
+		}
+	}
+
+	if opts.Mirrors {
+		if err := warnMissingRoots(cmd, repos, resolve); err != nil {
+			return err
+		}
+	}
+
+	repos, collapsed := dedupe.Dedupe(repos, opts)
+	dropped = append(dropped, collapsed...)
+
+	if err := writeResults(out, repos); err != nil {
+		return err
+	}
+
+	counts := map[dedupe.Reason]int{}
+
+	if report != "" {
+		fi, err := utils.TryCreate(report)
+		if err != nil {
+			return err
+		}
+		defer fi.Close()
+
+		if err = fi.Truncate(0); err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(fi)
+
+		for _, drop := range dropped {
+			if err := enc.Encode(dropRecord{
+				FullName: drop.Repo.FullName(),
+				Reason:   drop.Reason,
+				Kept:     drop.Kept,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, drop := range dropped {
+		counts[drop.Reason]++
+
+		logrus.Infof("Dropped %s as %s of %s", drop.Repo.FullName(), drop.Reason, drop.Kept)
+	}
+
+	_, err = fmt.Fprintf(
+		cmd.OutOrStdout(),
+		"Kept %d repositories, dropped %d: %d %s, %d %s, %d %s\n",
+		len(repos),
+		len(dropped),
+		counts[dedupe.Duplicate], dedupe.Duplicate,
+		counts[dedupe.Fork], dedupe.Fork,
+		counts[dedupe.Mirror], dedupe.Mirror,
+	)
+
+	return err
+}
+
+// resolveLineage fetches fork parents and root commits that weren't recorded during search.
+func resolveLineage(cmd *cobra.Command, repos []gh.RepoInfo, opts dedupe.Options, concurrency int) error {
+	ctx := cmd.Context()
+	client := gh.NewClient(ctx)
+	limiter := gh.NewLimiter(client, gh.WithLimit(gh.CoreLimit))
+
+	bar := pb.StartNew(len(repos))
+	defer bar.Finish()
+
+	var wg errgroup.Group
+	wg.SetLimit(concurrency)
+
+	for i := range repos {
+		i := i
+
+		wg.Go(func() error {
+			defer bar.Increment()
+
+			repo := repos[i]
+
+			if fork, _ := repo.Meta(gh.MetaFork); opts.Forks && cast.ToBool(fork) {
+				if _, ok := repo.Meta(gh.MetaParent); !ok {
+					if err := limiter.Wait(ctx); err != nil {
+						return err
+					}
+
+					repository, _, err := client.Repositories.Get(ctx, repo.Owner(), repo.Name())
+					if err != nil {
+						logrus.Errorf("Get %s err: %v", repo.FullName(), err)
+					} else if parent := repository.GetParent().GetFullName(); parent != "" {
+						repo = repo.WithMeta(gh.MetaParent, parent)
+					}
+				}
+			}
+
+			if _, ok := repo.Meta(gh.MetaRootSHA); opts.Mirrors && !ok {
+				if err := limiter.Wait(ctx); err != nil {
+					return err
+				}
+
+				branch, _ := repo.Meta(gh.MetaDefaultBranch)
+
+				root, err := client.RootCommit(ctx, repo.Owner(), repo.Name(), cast.ToString(branch))
+				if err != nil {
+					logrus.Errorf("Root commit for %s err: %v", repo.FullName(), err)
+				} else {
+					repo = repo.WithMeta(gh.MetaRootSHA, root)
+				}
+			}
+
+			repos[i] = repo
+
+			return nil
+		})
+	}
+
+	return wg.Wait()
+}
+
+// warnMissingRoots tells how many repositories can't be compared as mirrors, as search doesn't record root commits.
+func warnMissingRoots(cmd *cobra.Command, repos []gh.RepoInfo, resolved bool) error {
+	missing := 0
+
+	for _, repo := range repos {
+		if _, ok := repo.Meta(gh.MetaRootSHA); !ok {
+			missing++
+		}
+	}
+
+	if missing == 0 {
+		return nil
+	}
+
+	hint := "use --resolve to fetch them"
+	if resolved {
+		hint = "their lookup failed"
+	}
+
+	_, err := fmt.Fprintf(
+		cmd.ErrOrStderr(),
+		"Warning: %d of %d repositories have no root commit and won't be collapsed as mirrors, %s\n",
+		missing,
+		len(repos),
+		hint,
+	)
+
+	return err
+}
+
+func writeResults(path string, repos []gh.RepoInfo) error {
+	fi, err := utils.TryCreate(path)
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+
+	if err = fi.Truncate(0); err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(fi)
+
+	for _, repo := range repos {
+		if _, err := fmt.Fprintln(w, repo); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
@@ -1,15 +1,21 @@
 package internal
 
 import (
+	"fmt"
 	"github.com/cheggaaa/pb/v3"
 	"github.com/gaarutyunov/gh-exporter/gh"
+	"github.com/gaarutyunov/gh-exporter/lang"
 	"github.com/gaarutyunov/gh-exporter/plan"
+	"github.com/gaarutyunov/gh-exporter/retry"
 	"github.com/gaarutyunov/gh-exporter/utils"
 	"github.com/go-git/go-billy/v5/osfs"
-	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/term"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
 func Export(cmd *cobra.Command, args []string) error {
@@ -24,12 +30,26 @@ func Export(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	sshPath, err := cmd.PersistentFlags().GetString("identity")
+	cloneTransport, err := newCloneTransport(cmd)
 	if err != nil {
 		return err
 	}
-	sshPath = utils.ExpandPath(sshPath)
-	publicKey, err := ssh.NewPublicKeysFromFile("git", sshPath, "")
+
+	depth, err := cmd.PersistentFlags().GetInt("depth")
+	if err != nil {
+		return err
+	}
+
+	if depth < 0 {
+		return fmt.Errorf("depth must not be negative: %d", depth)
+	}
+
+	singleBranch, err := cmd.PersistentFlags().GetBool("single-branch")
+	if err != nil {
+		return err
+	}
+
+	exact, err := cmd.PersistentFlags().GetBool("exact")
 	if err != nil {
 		return err
 	}
@@ -40,11 +60,35 @@ func Export(cmd *cobra.Command, args []string) error {
 	}
 	planFile = utils.ExpandPath(planFile)
 
-	pattern, err := cmd.PersistentFlags().GetString("pattern")
+	languages, err := cmd.PersistentFlags().GetStringSlice("language")
 	if err != nil {
 		return err
 	}
 
+	if languages, err = lang.Parse(languages); err != nil {
+		return err
+	}
+
+	patterns, err := clonePatterns(cmd, len(languages) > 0)
+	if err != nil {
+		return err
+	}
+
+	content, err := contentFilter(cmd)
+	if err != nil {
+		return err
+	}
+
+	cloneOpts := gh.CloneOptions{
+		Transport:    cloneTransport,
+		Patterns:     patterns,
+		Content:      content,
+		Languages:    languages,
+		Depth:        depth,
+		SingleBranch: singleBranch,
+		Exact:        exact,
+	}
+
 	skipRemainder, err := cmd.PersistentFlags().GetBool("skip-remainder")
 	if err != nil {
 		return err
@@ -60,12 +104,129 @@ func Export(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	hugeConcurrency, err := cmd.PersistentFlags().GetInt("huge-concurrency")
+	if err != nil {
+		return err
+	}
+
+	bins, err := cmd.PersistentFlags().GetString("bins")
+	if err != nil {
+		return err
+	}
+
+	shard, err := cmd.PersistentFlags().GetString("shard")
+	if err != nil {
+		return err
+	}
+
+	retries, err := cmd.PersistentFlags().GetInt("retries")
+	if err != nil {
+		return err
+	}
+
+	backoff, err := cmd.PersistentFlags().GetDuration("backoff")
+	if err != nil {
+		return err
+	}
+
+	maxBackoff, err := cmd.PersistentFlags().GetDuration("max-backoff")
+	if err != nil {
+		return err
+	}
+
+	policy := retry.Policy{Attempts: retries, Initial: backoff, Max: maxBackoff}
+
+	failuresPath, err := cmd.PersistentFlags().GetString("failures")
+	if err != nil {
+		return err
+	}
+	failuresPath = utils.ExpandPath(failuresPath)
+
+	// derived from the plan, so that exporting a retry plan doesn't overwrite the report it was made from
+	if failuresPath == "" {
+		failuresPath = strings.TrimSuffix(planFile, filepath.Ext(planFile)) + ".failures.jsonl"
+	}
+
+	thresholdValue, err := cmd.PersistentFlags().GetString("fail-threshold")
+	if err != nil {
+		return err
+	}
+
+	var threshold *failThreshold
+
+	if thresholdValue != "" {
+		t, err := parseFailThreshold(thresholdValue)
+		if err != nil {
+			return err
+		}
+
+		threshold = &t
+	}
+
+	ledgerPath, err := cmd.PersistentFlags().GetString("ledger")
+	if err != nil {
+		return err
+	}
+
+	if ledgerPath == "" {
+		ledgerPath = filepath.Join(outDir, ledgerName)
+	}
+	ledgerPath = utils.ExpandPath(ledgerPath)
+
 	fin, err := plan.Open(planFile)
 	if err != nil {
 		return err
 	}
 
-	total := fin.Total(skipRemainder, onlyRemainder)
+	groups := fin.Iter(skipRemainder, onlyRemainder)
+
+	if bins != "" {
+		selectBins, err := plan.ParseBins(bins)
+		if err != nil {
+			return err
+		}
+
+		groups = selectBins(groups)
+	}
+
+	if shard != "" {
+		selectShard, err := plan.ParseShard(shard)
+		if err != nil {
+			return err
+		}
+
+		groups = selectShard(groups)
+	}
+
+	if err = os.MkdirAll(outDir, 0o755); err != nil {
+		return err
+	}
+
+	journal, err := openLedger(ledgerPath)
+	if err != nil {
+		return err
+	}
+	defer journal.Close()
+
+	for group := range groups {
+		for _, repoInfo := range group.Repos {
+			if _, ok := journal.get(repoInfo.FullName()); ok {
+				continue
+			}
+
+			if err = journal.record(ledgerEntry{FullName: repoInfo.FullName(), Status: statusPending, SHA: repoInfo.SHA()}); err != nil {
+				return err
+			}
+		}
+	}
+
+	summary := newExportSummary()
+
+	defer func() {
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), summary)
+	}()
+
+	total := plan.Count(groups)
 
 	bar := pb.StartNew(total)
 
@@ -75,7 +236,13 @@ func Export(cmd *cobra.Command, args []string) error {
 
 	ctx := cmd.Context()
 
-	for group, isRemainder := range fin.Iter(skipRemainder, onlyRemainder) {
+	failures, err := createFailureLog(failuresPath)
+	if err != nil {
+		return err
+	}
+	defer failures.Close()
+
+	for group := range groups {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
@@ -83,12 +250,16 @@ func Export(cmd *cobra.Command, args []string) error {
 		}
 
 		var wg errgroup.Group
-		wg.SetLimit(concurrency)
 
-		group := group
-		isRemainder := isRemainder
+		if group.Policy == plan.PolicyHuge {
+			wg.SetLimit(hugeConcurrency)
+		} else {
+			wg.SetLimit(concurrency)
+		}
 
-		for _, repoInfo := range group {
+		isRemainder := group.IsRemainder()
+
+		for _, repoInfo := range group.Repos {
 			select {
 			case <-ctx.Done():
 				return ctx.Err()
@@ -97,13 +268,19 @@ func Export(cmd *cobra.Command, args []string) error {
 
 			repository := gh.NewRepo(repoInfo, nil)
 
-			if ok, err := repository.Exists(outFs); err != nil {
+			if ok, err := journal.isExported(repository, outFs); err != nil {
 				return err
 			} else if ok {
+				summary.skipped.Add(1)
 				bar.AddTotal(-1)
 				continue
 			}
 
+			// a clone that isn't recorded as done in the ledger might be incomplete
+			if err := repository.Remove(outFs); err != nil {
+				return err
+			}
+
 			wg.Go(func() error {
 				select {
 				case <-ctx.Done():
@@ -118,11 +295,61 @@ func Export(cmd *cobra.Command, args []string) error {
 				}
 
 				defer bar.Increment()
-				if err := cloneFn(ctx, publicKey, pattern, outFs); err != nil {
-					logrus.Errorf("error for %s: %s", repository.FullName(), err)
+
+				if err := journal.record(ledgerEntry{FullName: repository.FullName(), Status: statusCloning, SHA: repository.SHA()}); err != nil {
+					return err
 				}
 
-				return nil
+				var stats gh.CloneStats
+
+				attempts, err := policy.Do(ctx, gh.IsRetryable, func(attempt int) (err error) {
+					if attempt > 1 {
+						// start over from a clean directory
+						if err = repository.Remove(outFs); err != nil {
+							return err
+						}
+					}
+
+					stats, err = cloneFn(ctx, cloneOpts, outFs)
+					if err != nil && attempt < policy.Attempts && gh.IsRetryable(err) {
+						logrus.Warnf("attempt %d for %s failed, retrying: %s", attempt, repository.FullName(), err)
+					}
+
+					return err
+				})
+				if err != nil {
+					logrus.Errorf("error for %s after %d attempts: %s", repository.FullName(), attempts, err)
+					summary.failed.Add(1)
+
+					failure := newFailureRecord(repoInfo, gh.StageClone, err)
+					failure.Group = group.ID
+					failure.Policy = group.Policy
+					failure.Attempts = attempts
+
+					if err := failures.record(failure); err != nil {
+						return err
+					}
+
+					return journal.record(ledgerEntry{
+						FullName: repository.FullName(),
+						Status:   statusFailed,
+						SHA:      repository.SHA(),
+						Attempts: attempts,
+						Error:    err.Error(),
+					})
+				}
+
+				summary.cloned.Add(1)
+				summary.bytes.Add(stats.Bytes)
+
+				return journal.record(ledgerEntry{
+					FullName: repository.FullName(),
+					Status:   statusDone,
+					SHA:      stats.SHA,
+					Files:    stats.Files,
+					Bytes:    stats.Bytes,
+					Attempts: attempts,
+				})
 			})
 		}
 
@@ -131,5 +358,175 @@ func Export(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	failed := summary.failed.Load()
+
+	if threshold != nil && threshold.exceeded(failed, failed+summary.cloned.Load()) {
+		return fmt.Errorf("%d repositories failed to export, exceeding the threshold of %s", failed, threshold)
+	}
+
 	return nil
 }
+
+// defaultInclude is kept when no include patterns are given and no languages are detected.
+const defaultInclude = "*.py"
+
+// clonePatterns combines the include and exclude flags with the patterns file and the deprecated pattern flag.
+// Without include patterns, all files are kept if languages are detected, since they select files themselves.
+func clonePatterns(cmd *cobra.Command, detectLanguages bool) (*gh.Patterns, error) {
+	include, err := cmd.PersistentFlags().GetStringArray("include")
+	if err != nil {
+		return nil, err
+	}
+
+	exclude, err := cmd.PersistentFlags().GetStringArray("exclude")
+	if err != nil {
+		return nil, err
+	}
+
+	patternsFile, err := cmd.PersistentFlags().GetString("patterns-file")
+	if err != nil {
+		return nil, err
+	}
+
+	if patternsFile != "" {
+		fileInclude, fileExclude, err := readPatterns(utils.ExpandPath(patternsFile))
+		if err != nil {
+			return nil, err
+		}
+
+		include = append(fileInclude, include...)
+		exclude = append(fileExclude, exclude...)
+	}
+
+	// deprecated alias of --include
+	if cmd.PersistentFlags().Changed("pattern") {
+		pattern, err := cmd.PersistentFlags().GetString("pattern")
+		if err != nil {
+			return nil, err
+		}
+
+		include = append(include, pattern)
+	}
+
+	if len(include) == 0 && detectLanguages {
+		include = []string{"*"}
+	} else if len(include) == 0 {
+		include = []string{defaultInclude}
+	}
+
+	return gh.NewPatterns(include, exclude)
+}
+
+func contentFilter(cmd *cobra.Command) (f gh.ContentFilter, err error) {
+	if f.MaxSize, err = cmd.PersistentFlags().GetInt64("max-file-size"); err != nil {
+		return
+	}
+
+	if f.MaxSize < 0 {
+		return f, fmt.Errorf("max file size must not be negative: %d", f.MaxSize)
+	}
+
+	if f.Binary, err = cmd.PersistentFlags().GetBool("skip-binary"); err != nil {
+		return
+	}
+
+	f.Generated, err = cmd.PersistentFlags().GetBool("skip-generated")
+
+	return
+}
+
+// readPatterns reads include patterns from the file, one per line, patterns starting with `!` are excludes.
+func readPatterns(path string) (include, exclude []string, err error) {
+	fi, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer fi.Close()
+
+	for line := range utils.IterLines(fi) {
+		if line = strings.TrimSpace(line); line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if p, ok := strings.CutPrefix(line, "!"); ok {
+			exclude = append(exclude, p)
+		} else {
+			include = append(include, line)
+		}
+	}
+
+	return
+}
+
+// newCloneTransport returns the transport selected by the transport flag.
+func newCloneTransport(cmd *cobra.Command) (gh.Transport, error) {
+	name, err := cmd.PersistentFlags().GetString("transport")
+	if err != nil {
+		return gh.Transport{}, err
+	}
+
+	switch name {
+	case gh.TransportSSH:
+		opts, err := sshOptions(cmd)
+		if err != nil {
+			return gh.Transport{}, err
+		}
+
+		auth, err := gh.NewSSHAuth(opts)
+		if err != nil {
+			return gh.Transport{}, err
+		}
+
+		return gh.NewSSHTransport(auth), nil
+	case gh.TransportHTTPS:
+		return gh.NewTokenTransport(os.Getenv("GITHUB_TOKEN"))
+	case gh.TransportAnonymous:
+		return gh.NewAnonymousTransport(), nil
+	default:
+		return gh.Transport{}, fmt.Errorf("unknown transport: %s", name)
+	}
+}
+
+func sshOptions(cmd *cobra.Command) (opts gh.SSHOptions, err error) {
+	if opts.Identity, err = cmd.PersistentFlags().GetString("identity"); err != nil {
+		return
+	}
+	opts.Identity = utils.ExpandPath(opts.Identity)
+
+	if opts.Agent, err = cmd.PersistentFlags().GetBool("ssh-agent"); err != nil {
+		return
+	}
+
+	if opts.KnownHosts, err = cmd.PersistentFlags().GetStringSlice("known-hosts"); err != nil {
+		return
+	}
+
+	for i, path := range opts.KnownHosts {
+		opts.KnownHosts[i] = utils.ExpandPath(path)
+	}
+
+	passphraseEnv, err := cmd.PersistentFlags().GetString("passphrase-env")
+	if err != nil {
+		return
+	}
+
+	opts.Passphrase = func() (string, error) {
+		if passphrase := os.Getenv(passphraseEnv); passphrase != "" {
+			return passphrase, nil
+		}
+
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return "", fmt.Errorf("%s is encrypted, set the passphrase in %s", opts.Identity, passphraseEnv)
+		}
+
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Enter passphrase for %s: ", opts.Identity)
+
+		passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+
+		_, _ = fmt.Fprintln(cmd.ErrOrStderr())
+
+		return string(passphrase), err
+	}
+
+	return
+}
@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"encoding/json"
+	"github.com/gaarutyunov/gh-exporter/gh"
+	"github.com/gaarutyunov/gh-exporter/plan"
+	"github.com/sirupsen/logrus"
+	"os"
+	"sync"
+)
+
+// failureRecord is a line of the failures file describing a repository that couldn't be processed.
+type failureRecord struct {
+	Repo gh.RepoInfo `json:"repo"`
+	// Group and Policy locate the repository in the plan it was exported from.
+	Group    string        `json:"group,omitempty"`
+	Policy   plan.Policy   `json:"policy,omitempty"`
+	Stage    gh.Stage      `json:"stage"`
+	Class    gh.ErrorClass `json:"class"`
+	Message  string        `json:"message"`
+	Attempts int           `json:"attempts,omitempty"`
+}
+
+func newFailureRecord(repo gh.RepoInfo, stage gh.Stage, err error) failureRecord {
+	return failureRecord{
+		Repo:    repo,
+		Stage:   gh.StageOf(err, stage),
+		Class:   gh.Classify(err),
+		Message: err.Error(),
+	}
+}
+
+// failureLog writes failure records of a single run as JSON Lines.
+type failureLog struct {
+	mu    sync.Mutex
+	path  string
+	fout  *os.File
+	count int
+}
+
+// createFailureLog truncates the failures file left by a previous run.
+func createFailureLog(path string) (*failureLog, error) {
+	fout, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &failureLog{path: path, fout: fout}, nil
+}
+
+func (l *failureLog) record(r failureRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err = l.fout.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	l.count++
+
+	return nil
+}
+
+func (l *failureLog) Close() error {
+	if l.count > 0 {
+		logrus.Errorf("%d repositories failed, see %s", l.count, l.path)
+	}
+
+	return l.fout.Close()
+}
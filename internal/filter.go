@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"bufio"
+	"github.com/gaarutyunov/gh-exporter/filter"
+	"github.com/gaarutyunov/gh-exporter/gh"
+	"github.com/gaarutyunov/gh-exporter/utils"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+func Filter(cmd *cobra.Command, args []string) error {
+	in, err := cmd.PersistentFlags().GetString("in")
+	if err != nil {
+		return err
+	}
+	in = utils.ExpandPath(in)
+
+	out, err := cmd.PersistentFlags().GetString("out")
+	if err != nil {
+		return err
+	}
+	out = utils.ExpandPath(out)
+
+	where, err := cmd.PersistentFlags().GetStringArray("where")
+	if err != nil {
+		return err
+	}
+
+	allowOwners, err := cmd.PersistentFlags().GetStringSlice("allow-owners")
+	if err != nil {
+		return err
+	}
+
+	denyOwners, err := cmd.PersistentFlags().GetStringSlice("deny-owners")
+	if err != nil {
+		return err
+	}
+
+	predicates := make([]filter.Predicate, 0, len(where)+1)
+
+	for _, expr := range where {
+		predicate, err := filter.Parse(expr)
+		if err != nil {
+			return err
+		}
+
+		predicates = append(predicates, predicate)
+	}
+
+	if allowOwners, err = readOwners(allowOwners); err != nil {
+		return err
+	}
+
+	if denyOwners, err = readOwners(denyOwners); err != nil {
+		return err
+	}
+
+	if len(allowOwners) > 0 || len(denyOwners) > 0 {
+		predicates = append(predicates, filter.Owners(allowOwners, denyOwners))
+	}
+
+	keep := filter.All(predicates...)
+
+	repos, err := readResults(in)
+	if err != nil {
+		return err
+	}
+
+	var kept []gh.RepoInfo
+
+	for _, repo := range repos {
+		if keep(repo) {
+			kept = append(kept, repo)
+		}
+	}
+
+	logrus.Infof("Kept %d of %d repositories", len(kept), len(repos))
+
+	return writeResults(out, kept)
+}
+
+// readResults reads all repositories from a results file in any format version.
+func readResults(path string) ([]gh.RepoInfo, error) {
+	fi, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fi.Close()
+
+	var repos []gh.RepoInfo
+
+	scanner := bufio.NewScanner(fi)
+	scanner.Buffer(make([]byte, bufio.MaxScanTokenSize), 1024*1024)
+
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		repo, err := gh.RepoInfoFromString(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+
+		repos = append(repos, repo)
+	}
+
+	return repos, scanner.Err()
+}
+
+// readOwners expands `@path` entries into owners listed in the file, one per line.
+func readOwners(owners []string) ([]string, error) {
+	var res []string
+
+	for _, owner := range owners {
+		path, ok := strings.CutPrefix(owner, "@")
+		if !ok {
+			res = append(res, owner)
+			continue
+		}
+
+		fi, err := os.Open(utils.ExpandPath(path))
+		if err != nil {
+			return nil, err
+		}
+
+		for line := range utils.IterLines(fi) {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				res = append(res, line)
+			}
+		}
+
+		if err := fi.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
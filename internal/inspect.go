@@ -0,0 +1,180 @@
+package internal
+
+import (
+	"fmt"
+	"github.com/gaarutyunov/gh-exporter/plan"
+	"github.com/gaarutyunov/gh-exporter/utils"
+	"github.com/spf13/cobra"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+const histogramWidth = 40
+
+func Inspect(cmd *cobra.Command, args []string) error {
+	file, err := cmd.PersistentFlags().GetString("file")
+	if err != nil {
+		return err
+	}
+	file = utils.ExpandPath(file)
+
+	bin, err := cmd.PersistentFlags().GetString("bin")
+	if err != nil {
+		return err
+	}
+
+	buckets, err := cmd.PersistentFlags().GetInt("buckets")
+	if err != nil {
+		return err
+	}
+
+	if buckets < 1 {
+		return fmt.Errorf("number of buckets must be positive: %d", buckets)
+	}
+
+	fi, err := plan.Open(file)
+	if err != nil {
+		return err
+	}
+
+	if bin != "" {
+		group, ok := fi.Group(bin)
+		if !ok {
+			return fmt.Errorf("no bin %s in %s", bin, file)
+		}
+
+		return printGroup(cmd.OutOrStdout(), group)
+	}
+
+	return printPlan(cmd.OutOrStdout(), file, fi, buckets)
+}
+
+func printGroup(w io.Writer, group plan.Group) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	for _, repo := range group.Repos {
+		if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\n", repo.FullName(), formatSize(repo.Size()), repo.SHA()); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintf(tw, "%d repositories\t%s\t\n", len(group.Repos), formatSize(plan.Size(group.Repos))); err != nil {
+		return err
+	}
+
+	return tw.Flush()
+}
+
+func printPlan(w io.Writer, path string, fi plan.File, buckets int) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Plan: %s\n", path)
+
+	if fi.Source != "" {
+		fmt.Fprintf(&b, "Source: %s\n", fi.Source)
+	}
+
+	if fi.Strategy != "" {
+		fmt.Fprintf(&b, "Strategy: %s\n", fi.Strategy)
+	}
+
+	capacity := fi.Capacity
+	if capacity > 0 {
+		fmt.Fprintf(&b, "Capacity: %s\n", formatSize(capacity))
+	}
+
+	fmt.Fprintf(&b, "Bins: %d with %d repositories\n", len(fi.Bins), fi.Total(true, false))
+
+	remainder := plan.Size(fi.Remainder)
+	fmt.Fprintf(&b, "Remainder: %d repositories, %s (%s)\n", len(fi.Remainder), formatSize(remainder), fi.Policy)
+
+	var total uint64
+	largest, smallest := -1, -1
+
+	for i, bin := range fi.Bins {
+		size := plan.Size(bin)
+		total += size
+
+		if largest < 0 || size > plan.Size(fi.Bins[largest]) {
+			largest = i
+		}
+
+		if smallest < 0 || size < plan.Size(fi.Bins[smallest]) {
+			smallest = i
+		}
+	}
+
+	fmt.Fprintf(&b, "Estimated download: %s\n", formatSize(total+remainder))
+
+	if largest >= 0 {
+		fmt.Fprintf(&b, "Largest bin: %s, %d repositories, %s\n", plan.BinID(largest), len(fi.Bins[largest]), formatSize(plan.Size(fi.Bins[largest])))
+		fmt.Fprintf(&b, "Smallest bin: %s, %d repositories, %s\n", plan.BinID(smallest), len(fi.Bins[smallest]), formatSize(plan.Size(fi.Bins[smallest])))
+
+		// legacy plans don't record the capacity, so the fill is relative to the largest bin
+		if capacity == 0 {
+			capacity = plan.Size(fi.Bins[largest])
+			b.WriteString("Fill histogram (relative to the largest bin):\n")
+		} else {
+			b.WriteString("Fill histogram:\n")
+		}
+
+		writeHistogram(&b, fi, capacity, buckets)
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+func writeHistogram(b *strings.Builder, fi plan.File, capacity uint64, buckets int) {
+	counts := make([]int, buckets)
+	most := 0
+
+	for _, bin := range fi.Bins {
+		i := 0
+		if capacity > 0 {
+			i = int(plan.Size(bin) * uint64(buckets) / capacity)
+		}
+
+		i = min(i, buckets-1)
+		counts[i]++
+		most = max(most, counts[i])
+	}
+
+	for i, count := range counts {
+		bar := 0
+		if most > 0 {
+			bar = count * histogramWidth / most
+		}
+
+		fmt.Fprintf(
+			b,
+			"  %3d-%3d%% %-*s %d\n",
+			i*100/buckets,
+			(i+1)*100/buckets,
+			histogramWidth,
+			strings.Repeat("#", bar),
+			count,
+		)
+	}
+}
+
+// formatSize formats a size in kilobytes.
+func formatSize(kb uint64) string {
+	return formatBytes(kb * 1024)
+}
+
+func formatBytes(n uint64) string {
+	size := float64(n)
+
+	for _, unit := range []string{"B", "KiB", "MiB", "GiB"} {
+		if size < 1024 {
+			return fmt.Sprintf("%.1f %s", size, unit)
+		}
+
+		size /= 1024
+	}
+
+	return fmt.Sprintf("%.1f TiB", size)
+}
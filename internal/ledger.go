@@ -0,0 +1,135 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/gaarutyunov/gh-exporter/gh"
+	"github.com/go-git/go-billy/v5"
+	"github.com/sirupsen/logrus"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const ledgerName = ".ledger.jsonl"
+
+type exportStatus string
+
+const (
+	statusPending exportStatus = "pending"
+	statusCloning exportStatus = "cloning"
+	statusDone    exportStatus = "done"
+	statusFailed  exportStatus = "failed"
+)
+
+// ledgerEntry is a line of the export ledger, the last entry of a repository is its current state.
+type ledgerEntry struct {
+	FullName string       `json:"full_name"`
+	Status   exportStatus `json:"status"`
+	SHA      string       `json:"sha,omitempty"`
+	Files    int          `json:"files,omitempty"`
+	Bytes    int64        `json:"bytes,omitempty"`
+	Attempts int          `json:"attempts,omitempty"`
+	Error    string       `json:"error,omitempty"`
+	Time     time.Time    `json:"time"`
+}
+
+// ledger is an append-only journal of export progress shared by concurrent clones.
+type ledger struct {
+	mu      sync.Mutex
+	fout    *os.File
+	entries map[string]ledgerEntry
+}
+
+// openLedger replays the journal at path and opens it for appending.
+// A line cut off by an interrupted run is ignored.
+func openLedger(path string) (*ledger, error) {
+	l := &ledger{entries: make(map[string]ledgerEntry)}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var entry ledgerEntry
+
+		if err := json.Unmarshal(line, &entry); err != nil {
+			logrus.Warnf("skipping invalid ledger entry %q: %s", line, err)
+			continue
+		}
+
+		l.entries[strings.ToLower(entry.FullName)] = entry
+	}
+
+	l.fout, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	// terminate a cut off line, so that it doesn't swallow the next entry
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		if _, err := l.fout.Write([]byte("\n")); err != nil {
+			return nil, errors.Join(err, l.fout.Close())
+		}
+	}
+
+	return l, nil
+}
+
+func (l *ledger) get(fullName string) (ledgerEntry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.entries[strings.ToLower(fullName)]
+
+	return entry, ok
+}
+
+// isDone tells whether the repository was exported at the planned revision.
+func (l *ledger) isDone(repo gh.RepoInfo) bool {
+	entry, ok := l.get(repo.FullName())
+
+	return ok && entry.Status == statusDone && (repo.SHA() == "" || strings.EqualFold(repo.SHA(), entry.SHA))
+}
+
+// isExported tells whether the repository was exported at the planned revision and its directory is still there.
+func (l *ledger) isExported(repo *gh.Repo, fs billy.Filesystem) (bool, error) {
+	if !l.isDone(repo.RepoInfo) {
+		return false, nil
+	}
+
+	return repo.Exists(fs)
+}
+
+func (l *ledger) record(entry ledgerEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.fout.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	l.entries[strings.ToLower(entry.FullName)] = entry
+
+	return nil
+}
+
+func (l *ledger) Close() error {
+	return l.fout.Close()
+}
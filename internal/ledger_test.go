@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"github.com/gaarutyunov/gh-exporter/gh"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	ledgerSHA   = "274ecf0e19e8da03197bdda8f2c5be307ad6aa69"
+	ledgerOther = "40d5d2edccd00b4a66fb0e24d887d8b1a0d7ea0e"
+)
+
+func TestLedger_isExported(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ledgerName)
+
+	journal, err := openLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, journal.record(ledgerEntry{FullName: "a/done", Status: statusDone, SHA: ledgerSHA}))
+	assert.NoError(t, journal.record(ledgerEntry{FullName: "a/failed", Status: statusDone, SHA: ledgerSHA}))
+	assert.NoError(t, journal.record(ledgerEntry{FullName: "a/failed", Status: statusFailed}))
+	assert.NoError(t, journal.record(ledgerEntry{FullName: "a/cloning", Status: statusCloning}))
+	assert.NoError(t, journal.Close())
+
+	// entries are replayed from the journal
+	journal, err = openLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+
+	fs := memfs.New()
+
+	for _, dir := range []string{"a.done", "a.failed", "a.cloning"} {
+		if err := fs.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		repo     gh.RepoInfo
+		fs       billy.Filesystem
+		exported bool
+	}{
+		{gh.NewRepoInfo("a/done", "", 1).WithSHA(ledgerSHA), nil, true},
+		{gh.NewRepoInfo("a/done", "", 1), nil, true},
+		{gh.NewRepoInfo("a/done", "", 1).WithSHA(ledgerOther), nil, false},
+		{gh.NewRepoInfo("a/done", "", 1).WithSHA(ledgerSHA), memfs.New(), false},
+		{gh.NewRepoInfo("a/failed", "", 1).WithSHA(ledgerSHA), nil, false},
+		{gh.NewRepoInfo("a/cloning", "", 1), nil, false},
+		{gh.NewRepoInfo("a/new", "", 1), nil, false},
+	} {
+		out := fs
+		if tc.fs != nil {
+			out = tc.fs
+		}
+
+		exported, err := journal.isExported(gh.NewRepo(tc.repo, nil), out)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tc.exported, exported, "%s@%s", tc.repo.FullName(), tc.repo.SHA())
+	}
+
+	// GitHub names are case-insensitive
+	assert.True(t, journal.isDone(gh.NewRepoInfo("A/Done", "", 1).WithSHA(ledgerSHA)))
+}
+
+func TestLedger_CutOffLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ledgerName)
+
+	data := `{"full_name":"a/done","status":"done","sha":"` + ledgerSHA + `","time":"2024-12-01T10:00:00Z"}` + "\n" +
+		`{"full_name":"a/cut","status":"do`
+
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	journal, err := openLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, journal.isDone(gh.NewRepoInfo("a/done", "", 1).WithSHA(ledgerSHA)))
+	assert.False(t, journal.isDone(gh.NewRepoInfo("a/cut", "", 1)))
+
+	assert.NoError(t, journal.record(ledgerEntry{FullName: "a/cut", Status: statusDone}))
+	assert.NoError(t, journal.Close())
+
+	// the entry appended after the cut off line is not swallowed by it
+	journal, err = openLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+
+	assert.True(t, journal.isDone(gh.NewRepoInfo("a/done", "", 1).WithSHA(ledgerSHA)))
+	assert.True(t, journal.isDone(gh.NewRepoInfo("a/cut", "", 1)))
+}
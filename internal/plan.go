@@ -1,7 +1,7 @@
 package internal
 
 import (
-	"bufio"
+	"fmt"
 	"github.com/gaarutyunov/gh-exporter/binpack"
 	"github.com/gaarutyunov/gh-exporter/gh"
 	"github.com/gaarutyunov/gh-exporter/plan"
@@ -30,11 +30,46 @@ func Plan(cmd *cobra.Command, args []string) (err error) {
 
 	capacity /= uint64(cache.KiByte)
 
-	fin, err := utils.TryCreate(in)
+	maxRepos, err := cmd.PersistentFlags().GetInt("max-repos")
+	if err != nil {
+		return err
+	}
+
+	maxPerOwner, err := cmd.PersistentFlags().GetInt("max-per-owner")
+	if err != nil {
+		return err
+	}
+
+	strategyName, err := cmd.PersistentFlags().GetString("strategy")
+	if err != nil {
+		return err
+	}
+
+	strategy, err := binpack.New[gh.RepoInfo](strategyName)
+	if err != nil {
+		return err
+	}
+
+	policyName, err := cmd.PersistentFlags().GetString("remainder")
+	if err != nil {
+		return err
+	}
+
+	policy, err := plan.ParsePolicy(policyName)
+	if err != nil {
+		return err
+	}
+
+	excluded, err := cmd.PersistentFlags().GetString("excluded")
+	if err != nil {
+		return err
+	}
+	excluded = utils.ExpandPath(excluded)
+
+	items, err := readResults(in)
 	if err != nil {
 		return err
 	}
-	defer fin.Close()
 
 	fout, err := utils.TryCreate(out)
 	if err != nil {
@@ -42,28 +77,47 @@ func Plan(cmd *cobra.Command, args []string) (err error) {
 	}
 	defer fout.Close()
 
-	scanner := bufio.NewScanner(fin)
+	if err = fout.Truncate(0); err != nil {
+		return err
+	}
 
-	var items []gh.RepoInfo
+	bins, remainder := strategy.Pack(items, binpack.Constraints{
+		Capacity:    capacity,
+		MaxItems:    maxRepos,
+		MaxPerOwner: maxPerOwner,
+	})
 
-	for scanner.Scan() {
-		repo, err := gh.RepoInfoFromString(scanner.Text())
-		if err != nil {
-			return err
-		}
-		items = append(items, repo)
-	}
+	planFile := plan.New(bins, remainder).WithPolicy(policy)
+	planFile.Source = in
+	planFile.Strategy = strategyName
+	planFile.Capacity = capacity
+	planFile.MaxRepos = maxRepos
+	planFile.MaxPerOwner = maxPerOwner
 
-	if err = scanner.Err(); err != nil {
+	data, err := planFile.MarshalJSON()
+	if err != nil {
 		return err
 	}
 
-	planFile := plan.New(binpack.FirstFit(items, capacity))
+	_, err = fout.Write(append(data, '\n'))
+	if err != nil {
+		return err
+	}
 
-	_, err = fout.WriteString(planFile.String())
+	_, err = fmt.Fprintf(cmd.OutOrStdout(), "%s: %s\n", strategyName, binpack.Summarize(bins, remainder, capacity))
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if policy != plan.PolicyExclude || len(remainder) == 0 {
+		return nil
+	}
+
+	if err = writeResults(excluded, remainder); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(cmd.OutOrStdout(), "%d oversized repositories excluded to %s\n", len(remainder), excluded)
+
+	return err
 }
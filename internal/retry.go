@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gaarutyunov/gh-exporter/gh"
+	"github.com/gaarutyunov/gh-exporter/plan"
+	"github.com/gaarutyunov/gh-exporter/utils"
+	"github.com/spf13/cobra"
+	"os"
+	"slices"
+	"strings"
+)
+
+func Retry(cmd *cobra.Command, args []string) error {
+	in, err := cmd.PersistentFlags().GetString("in")
+	if err != nil {
+		return err
+	}
+	in = utils.ExpandPath(in)
+
+	out, err := cmd.PersistentFlags().GetString("out")
+	if err != nil {
+		return err
+	}
+	out = utils.ExpandPath(out)
+
+	format, err := cmd.PersistentFlags().GetString("format")
+	if err != nil {
+		return err
+	}
+
+	classes, err := cmd.PersistentFlags().GetStringSlice("class")
+	if err != nil {
+		return err
+	}
+
+	stages, err := cmd.PersistentFlags().GetStringSlice("stage")
+	if err != nil {
+		return err
+	}
+
+	records, err := readFailures(in)
+	if err != nil {
+		return err
+	}
+
+	records = slices.DeleteFunc(records, func(r failureRecord) bool {
+		return len(classes) > 0 && !slices.Contains(classes, string(r.Class)) ||
+			len(stages) > 0 && !slices.Contains(stages, string(r.Stage))
+	})
+
+	switch format {
+	case "plan":
+		err = writeRetryPlan(out, in, records)
+	case "results":
+		repos := make([]gh.RepoInfo, 0, len(records))
+		for _, r := range records {
+			repos = append(repos, r.Repo)
+		}
+
+		err = writeResults(out, repos)
+	default:
+		err = fmt.Errorf("unknown retry format: %s", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(cmd.OutOrStdout(), "%d repositories to retry written to %s\n", len(records), out)
+
+	return err
+}
+
+func readFailures(path string) (records []failureRecord, err error) {
+	fin, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fin.Close()
+
+	for line := range utils.IterLines(fin) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		var r failureRecord
+
+		if err = json.Unmarshal([]byte(line), &r); err != nil {
+			return nil, fmt.Errorf("invalid failure record %q: %w", line, err)
+		}
+
+		records = append(records, r)
+	}
+
+	return
+}
+
+// writeRetryPlan writes failed repositories as a plan keeping the groups they were exported in.
+func writeRetryPlan(path, source string, records []failureRecord) error {
+	var bins [][]gh.RepoInfo
+	var remainder []gh.RepoInfo
+
+	policy := plan.PolicyKeep
+	index := make(map[string]int)
+
+	for _, r := range records {
+		if r.Repo.SshURL() == "" {
+			return fmt.Errorf("%s has no SSH URL, convert %s failures to results and scan them again", r.Repo.FullName(), r.Stage)
+		}
+
+		if r.Policy != "" {
+			policy = r.Policy
+			remainder = append(remainder, r.Repo)
+			continue
+		}
+
+		i, ok := index[r.Group]
+		if !ok {
+			i = len(bins)
+			index[r.Group] = i
+			bins = append(bins, nil)
+		}
+
+		bins[i] = append(bins[i], r.Repo)
+	}
+
+	planFile := plan.New(bins, remainder).WithPolicy(policy)
+	planFile.Source = source
+
+	data, err := planFile.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -1,15 +1,16 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
 	"github.com/cheggaaa/pb/v3"
 	"github.com/gaarutyunov/gh-exporter/gh"
 	"github.com/gaarutyunov/gh-exporter/utils"
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/errgroup"
-	"net/http"
 	"os"
 	"regexp"
+	"strings"
 )
 
 var repoRegExp = regexp.MustCompile("https://github\\.com/([^/]+)/([^/]+).git")
@@ -40,6 +41,17 @@ func Scan(cmd *cobra.Command, args []string) (err error) {
 		return err
 	}
 
+	backend, err := cmd.PersistentFlags().GetString("backend")
+	if err != nil {
+		return err
+	}
+
+	failuresPath, err := cmd.PersistentFlags().GetString("failures")
+	if err != nil {
+		return err
+	}
+	failuresPath = utils.ExpandPath(failuresPath)
+
 	fIn, err := os.Open(in)
 	if err != nil {
 		return err
@@ -66,110 +78,210 @@ func Scan(cmd *cobra.Command, args []string) (err error) {
 		_ = fOut.Close()
 	}(fOut)
 
+	failures, err := createFailureLog(failuresPath)
+	if err != nil {
+		return err
+	}
+	defer failures.Close()
+
+	fail := func(entry scanEntry, err error) error {
+		bar.AddTotal(-1)
+
+		return failures.record(newFailureRecord(entry.repoInfo(), gh.StageLookup, err))
+	}
+
 	c := gh.NewClient(cmd.Context())
 
+	var limiter *gh.Limiter
+
+	switch backend {
+	case "rest":
+	case "graphql":
+		limiter = gh.NewLimiter(c, gh.WithLimit(gh.GraphQLLimit))
+	default:
+		return fmt.Errorf("unknown backend: %s", backend)
+	}
+
+	// every entry yields at most a line, so lookups never block on writing
 	linesCh := make(chan string, lines)
-	errCh := make(chan error)
-	done := make(chan struct{})
 
-	defer func() {
-		close(errCh)
-		close(done)
-	}()
+	var g errgroup.Group
+
+	g.Go(func() error {
+		defer close(linesCh)
+		defer fIn.Close()
 
-	go func() {
 		var wg errgroup.Group
 		wg.SetLimit(concurrency)
 
-		defer func() {
-			_ = fIn.Close()
-			if err != nil {
-				errCh <- err
-			}
-		}()
-
-		for line := range utils.IterLines(fIn) {
-			select {
-			case <-ctx.Done():
-				break
-			default:
-			}
-
-			line := line
+		var batch []scanEntry
 
+		flush := func(batch []scanEntry) {
 			wg.Go(func() error {
-				select {
-				case <-ctx.Done():
-					return ctx.Err()
-				default:
+				if err := limiter.Wait(ctx); err != nil {
+					return err
 				}
 
-				var url, sha string // TODO: switch to named args
+				names := make([]string, 0, len(batch))
+				for _, entry := range batch {
+					names = append(names, entry.fullName)
+				}
 
-				_, err := fmt.Sscanf(line, format, &url, &sha)
-				if err != nil {
+				repos, err := c.LookupRepositories(ctx, names)
+				if gh.IsRateLimited(err) {
 					return err
+				} else if err != nil {
+					for _, entry := range batch {
+						if err := fail(entry, err); err != nil {
+							return err
+						}
+					}
+
+					return nil
 				}
 
-				var owner, repo string
+				for _, entry := range batch {
+					repository, ok := repos[entry.fullName]
+					if !ok {
+						if err := fail(entry, gh.ErrNotFound); err != nil {
+							return err
+						}
 
-				for _, s := range repoRegExp.FindAllStringSubmatch(url, 1) {
-					owner, repo = s[1], s[2]
-				}
+						continue
+					}
 
-				repository, resp, err := c.Repositories.Get(cmd.Context(), owner, repo)
-				if resp.StatusCode == http.StatusNotFound {
-					bar.AddTotal(-1)
-					return nil
-				}
-				if err != nil {
-					return err
-				}
+					info := repository.RepoInfo()
+					if entry.sha != "" {
+						info = info.WithSHA(entry.sha)
+					}
 
-				linesCh <- fmt.Sprintf(
-					"%s;%s;%d;%s\n",
-					repository.GetFullName(),
-					repository.GetSSHURL(),
-					repository.GetSize(),
-					sha,
-				)
+					linesCh <- info.String() + "\n"
+				}
 
 				return nil
 			})
 		}
 
-		if err := wg.Wait(); err != nil {
-			errCh <- err
-		}
+		err := func() error {
+			for line := range utils.IterLines(fIn) {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
 
-		close(linesCh)
-	}()
+				line := line
 
-	go func() {
-		for line := range linesCh {
+				if backend == "graphql" {
+					entry, err := parseScanLine(line, format)
+					if err != nil {
+						return err
+					}
 
-			select {
-			case <-ctx.Done():
-				return
-			default:
+					if batch = append(batch, entry); len(batch) == gh.GraphQLPageSize {
+						flush(batch)
+						batch = nil
+					}
+
+					continue
+				}
+
+				wg.Go(func() error {
+					select {
+					case <-ctx.Done():
+						return ctx.Err()
+					default:
+					}
+
+					entry, err := parseScanLine(line, format)
+					if err != nil {
+						return err
+					}
+
+					// every following lookup would fail the same way, so the scan stops instead of reporting them
+					repository, _, err := c.Repositories.Get(cmd.Context(), entry.owner(), entry.name())
+					if gh.IsRateLimited(err) {
+						return err
+					} else if err != nil {
+						return fail(entry, err)
+					}
+
+					info := gh.RepoInfoFromRepository(repository).WithSHA(entry.sha)
+
+					linesCh <- info.String() + "\n"
+
+					return nil
+				})
 			}
 
-			_, err := fOut.WriteString(line)
-			if err != nil {
-				errCh <- err
+			if len(batch) > 0 {
+				flush(batch)
 			}
+
+			return nil
+		}()
+
+		// lookups in flight write to linesCh, so it is closed only after they finish
+		return errors.Join(err, wg.Wait())
+	})
+
+	g.Go(func() error {
+		for line := range linesCh {
+			if _, err := fOut.WriteString(line); err != nil {
+				return err
+			}
+
 			bar.Increment()
 		}
-		done <- struct{}{}
-	}()
 
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-done:
 		return nil
-	case err = <-errCh:
-		close(linesCh)
+	})
+
+	return g.Wait()
+}
+
+type scanEntry struct {
+	fullName string
+	sha      string
+}
+
+func (e scanEntry) repoInfo() gh.RepoInfo {
+	return gh.NewRepoInfo(e.fullName, "", 0).WithSHA(e.sha)
+}
+
+func (e scanEntry) owner() string {
+	owner, _, _ := strings.Cut(e.fullName, "/")
+	return owner
+}
+
+func (e scanEntry) name() string {
+	_, name, _ := strings.Cut(e.fullName, "/")
+	return name
+}
+
+// parseScanLine reads a line of the input spec in the given format.
+// Lines of a results file in any format version are accepted as well.
+func parseScanLine(line, format string) (entry scanEntry, err error) {
+	var url string // TODO: switch to named args
+
+	if strings.HasPrefix(line, "{") {
+		repo, err := gh.RepoInfoFromString(line)
+		return scanEntry{fullName: repo.FullName(), sha: repo.SHA()}, err
+	}
+
+	if _, err = fmt.Sscanf(line, format, &url, &entry.sha); err != nil {
+		if repo, rerr := gh.RepoInfoFromString(line); rerr == nil {
+			return scanEntry{fullName: repo.FullName(), sha: repo.SHA()}, nil
+		}
+
 		return
 	}
+
+	for _, s := range repoRegExp.FindAllStringSubmatch(url, 1) {
+		entry.fullName = s[1] + "/" + s[2]
+	}
+
+	if entry.fullName == "" {
+		err = fmt.Errorf("invalid repository url: %s", url)
+	}
+
+	return
 }
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
 	"github.com/cheggaaa/pb/v3"
 	"github.com/gaarutyunov/gh-exporter/gh"
@@ -9,11 +10,16 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"os"
-	"sync/atomic"
 )
 
 const reposPerPage = 100
 
+type searchItem struct {
+	repo   *gh.Repo
+	slice  int
+	cursor string
+}
+
 func Search(cmd *cobra.Command, args []string) error {
 	query, err := cmd.PersistentFlags().GetString("query")
 	if err != nil {
@@ -30,20 +36,58 @@ func Search(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	resume, err := cmd.PersistentFlags().GetBool("resume")
+	if err != nil {
+		return err
+	}
+
+	slice, err := cmd.PersistentFlags().GetStringSlice("slice")
+	if err != nil {
+		return err
+	}
+
+	backendName, err := cmd.PersistentFlags().GetString("backend")
+	if err != nil {
+		return err
+	}
+
+	out, err := cmd.PersistentFlags().GetString("out")
+	if err != nil {
+		return err
+	}
+	out = utils.ExpandPath(out)
+
+	cpPath := checkpointPath(out)
+	cp := searchCheckpoint{Query: query}
+	seen := map[string]struct{}{}
+
+	if resume {
+		if cp, err = loadCheckpoint(cpPath); err != nil {
+			return err
+		}
+
+		if cp.Query != query {
+			return fmt.Errorf("checkpoint %s was made for query %q, not %q", cpPath, cp.Query, query)
+		}
+
+		if seen, err = readSeen(out); err != nil {
+			return err
+		}
+	}
+
 	client := gh.NewClient(cmd.Context())
 
+	backend, err := newSearchBackend(backendName, client, burst)
+	if err != nil {
+		return err
+	}
+
 	searchLimiter := gh.NewLimiter(
 		client,
 		gh.WithLimit(gh.SearchLimit),
 		gh.WithBurst(burst),
 	)
 
-	coreLimiter := gh.NewLimiter(
-		client,
-		gh.WithLimit(gh.CoreLimit),
-		gh.WithBurst(burst),
-	)
-
 	res, _, err := client.Search.Repositories(cmd.Context(), query, &github.SearchOptions{
 		TextMatch: false,
 		ListOptions: github.ListOptions{
@@ -55,136 +99,175 @@ func Search(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	out, err := cmd.PersistentFlags().GetString("out")
-	if err != nil {
-		return err
+	if !resume && len(slice) > 0 && res.GetTotal() >= searchCap {
+		slicer := querySlicer{client: client, limiter: searchLimiter}
+
+		if cp.Slices, err = slicer.Slice(cmd.Context(), query, slice); err != nil {
+			return err
+		}
+
+		logrus.Infof("Query %q was sliced into %d queries", query, len(cp.Slices))
 	}
-	out = utils.ExpandPath(out)
+
+	if len(cp.Slices) == 0 {
+		cp.Slices = []string{query}
+	}
+
 	var fi *os.File
 
-	if fi, err = utils.TryCreate(out); err != nil {
+	if resume {
+		fi, err = os.OpenFile(out, os.O_CREATE|os.O_WRONLY|os.O_APPEND, os.ModePerm)
+	} else if fi, err = utils.TryCreate(out); err == nil {
+		err = fi.Truncate(0)
+	}
+	if err != nil {
 		return err
 	}
 
 	defer fi.Close()
 
-	var counter, doneCounter atomic.Int64
-
 	if limit > 0 {
 		limit = min(limit, int64(res.GetTotal()))
-
-		counter.Store(limit)
-		doneCounter.Store(limit)
 	} else {
-		counter.Store(int64(res.GetTotal()))
-		doneCounter.Store(int64(res.GetTotal()))
+		limit = int64(res.GetTotal())
 	}
 
+	counter := max(limit-cp.Emitted, 0)
+
 	bar := pb.StartNew(int(limit))
+	bar.SetCurrent(min(cp.Emitted, limit))
 
 	defer bar.Finish()
 
-	perPage := min(int(limit), reposPerPage)
+	if cp.PerPage == 0 {
+		cp.PerPage = min(int(limit), reposPerPage)
+	}
 
-	page := 0
+	if err := cp.save(cpPath); err != nil {
+		return err
+	}
 
-	repoCh := make(chan *gh.Repo)
+	// the consumer updates the checkpoint while the producer pages from where it started
+	perPage, startSlice, startCursor, slices := cp.PerPage, cp.Slice, cp.Cursor, cp.Slices
 
-	defer close(repoCh)
+	repoCh := make(chan searchItem)
+	done := make(chan struct{})
 
 	go func() {
-		for repo := range repoCh {
+		defer close(done)
+
+		for item := range repoCh {
 			select {
 			case <-cmd.Context().Done():
 				return
 			default:
 			}
 
-			err := coreLimiter.Wait(cmd.Context())
-			if err != nil {
+			repo := item.repo
+
+			if err := backend.Resolve(cmd.Context(), repo); err != nil {
+				logrus.Errorf("Resolve %s err: %v", repo.FullName(), err)
+				bar.AddTotal(-1)
 				continue
 			}
 
-			defaultBranch := repo.GetDefaultBranch()
-
-			commits, _, err := client.Repositories.ListCommits(cmd.Context(), repo.Owner(), repo.Name(), &github.CommitsListOptions{
-				SHA: defaultBranch,
-				ListOptions: github.ListOptions{
-					Page:    0,
-					PerPage: 1,
-				},
-			})
-			if err != nil {
-				logrus.Errorf("List commits for %s err: %v", repo.FullName(), err)
+			if _, err := fmt.Fprintln(fi, repo); err != nil {
+				logrus.Errorf("Write %s err: %v", repo.FullName(), err)
 				bar.AddTotal(-1)
-				doneCounter.Add(-1)
 				continue
 			}
 
-			if len(commits) > 0 {
-				repo.SetSHA(commits[0].GetSHA())
-			}
+			cp.Slice = item.slice
+			cp.Cursor = item.cursor
+			cp.Emitted++
 
-			if _, err = fmt.Fprintln(fi, repo); err != nil {
-				bar.AddTotal(-1)
-				doneCounter.Add(-1)
-				continue
+			if err := cp.save(cpPath); err != nil {
+				logrus.Errorf("Save checkpoint %s err: %v", cpPath, err)
 			}
 
 			bar.Increment()
-			doneCounter.Add(-1)
 		}
 	}()
 
-	for counter.Load() > 0 {
-		select {
-		case <-cmd.Context().Done():
-			return cmd.Context().Err()
-		default:
-		}
+	err = func() error {
+		defer close(repoCh)
 
-		if err := searchLimiter.Wait(cmd.Context()); err != nil {
-			return err
-		}
+		for i := startSlice; i < len(slices) && counter > 0; i++ {
+			cursor := ""
+			if i == startSlice {
+				cursor = startCursor
+			}
 
-		res, _, err := client.Search.Repositories(cmd.Context(), query, &github.SearchOptions{
-			TextMatch: false,
-			ListOptions: github.ListOptions{
-				Page:    page,
-				PerPage: perPage,
-			},
-		})
-		if err != nil {
-			return err
-		}
+			for counter > 0 {
+				repos, next, err := backend.Page(cmd.Context(), slices[i], cursor, perPage)
+				if err != nil {
+					return err
+				}
+
+				for j := 0; j < len(repos) && counter > 0; j++ {
+					if _, ok := seen[repos[j].FullName()]; ok {
+						continue
+					}
 
-		l := min(len(res.Repositories), int(counter.Load()))
+					seen[repos[j].FullName()] = struct{}{}
 
-		for i := 0; i < l; i++ {
-			repository := res.Repositories[i]
+					select {
+					case <-cmd.Context().Done():
+						return cmd.Context().Err()
+					case repoCh <- searchItem{repo: repos[j], slice: i, cursor: cursor}:
+					}
 
-			repoCh <- gh.NewRepo(
-				gh.NewRepoInfo(
-					repository.GetFullName(),
-					repository.GetSSHURL(),
-					uint64(repository.GetSize()),
-				),
-				repository,
-			)
+					counter--
+				}
 
-			counter.Add(-1)
+				if next == "" {
+					break
+				}
+
+				cursor = next
+			}
 		}
 
-		page++
+		return nil
+	}()
+
+	<-done
+
+	if err != nil {
+		return err
+	}
+
+	if counter > 0 {
+		bar.AddTotal(-counter)
 	}
 
-	for !doneCounter.CompareAndSwap(0, 0) {
-		select {
-		case <-cmd.Context().Done():
-			return cmd.Context().Err()
-		default:
+	return cmd.Context().Err()
+}
+
+// readSeen collects full names of repositories already written to the results file.
+func readSeen(path string) (map[string]struct{}, error) {
+	seen := map[string]struct{}{}
+
+	fi, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return seen, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer fi.Close()
+
+	for line := range utils.IterLines(fi) {
+		if line == "" {
+			continue
 		}
+
+		repo, err := gh.RepoInfoFromString(line)
+		if err != nil {
+			return nil, err
+		}
+
+		seen[repo.FullName()] = struct{}{}
 	}
 
-	return nil
+	return seen, nil
 }
@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"github.com/gaarutyunov/gh-exporter/gh"
+	"github.com/google/go-github/v45/github"
+	"github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+// searchCap is the maximum number of results GitHub search returns for a single query.
+const searchCap = 1000
+
+const (
+	dateLayout = "2006-01-02"
+	day        = 24 * time.Hour
+)
+
+var firstRepoDate = time.Date(2007, time.October, 1, 0, 0, 0, 0, time.UTC)
+
+// sliceDimension is a search qualifier with an integer range that can be bisected.
+type sliceDimension struct {
+	lo, hi    int64
+	qualifier func(lo, hi int64) string
+}
+
+func newSliceDimension(name string) (sliceDimension, error) {
+	switch name {
+	case "created":
+		return sliceDimension{
+			lo: 0,
+			hi: int64(time.Now().UTC().Sub(firstRepoDate) / day),
+			qualifier: func(lo, hi int64) string {
+				return fmt.Sprintf(
+					"created:%s..%s",
+					firstRepoDate.Add(time.Duration(lo)*day).Format(dateLayout),
+					firstRepoDate.Add(time.Duration(hi)*day).Format(dateLayout),
+				)
+			},
+		}, nil
+	case "size":
+		return sliceDimension{
+			lo: 0,
+			hi: 1 << 30,
+			qualifier: func(lo, hi int64) string {
+				return fmt.Sprintf("size:%d..%d", lo, hi)
+			},
+		}, nil
+	default:
+		return sliceDimension{}, fmt.Errorf("unknown slice dimension: %s", name)
+	}
+}
+
+type querySlicer struct {
+	client  *gh.Client
+	limiter *gh.Limiter
+}
+
+// Slice splits the query into sub-queries holding fewer than searchCap results each.
+// Dimensions are bisected in order, the next one is used once a range of the previous one can't be split anymore.
+func (s querySlicer) Slice(ctx context.Context, query string, dimensions []string) ([]string, error) {
+	dims := make([]sliceDimension, 0, len(dimensions))
+
+	for _, name := range dimensions {
+		dim, err := newSliceDimension(name)
+		if err != nil {
+			return nil, err
+		}
+
+		dims = append(dims, dim)
+	}
+
+	if len(dims) == 0 {
+		return []string{query}, nil
+	}
+
+	return s.split(ctx, query, dims, dims[0].lo, dims[0].hi)
+}
+
+func (s querySlicer) split(ctx context.Context, query string, dims []sliceDimension, lo, hi int64) ([]string, error) {
+	q := strings.Join([]string{query, dims[0].qualifier(lo, hi)}, " ")
+
+	total, err := s.count(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	if total == 0 {
+		return nil, nil
+	} else if total < searchCap {
+		return []string{q}, nil
+	}
+
+	if lo == hi {
+		if len(dims) == 1 {
+			logrus.Warnf("Query %q can't be sliced further, only %d of %d results are reachable", q, searchCap, total)
+			return []string{q}, nil
+		}
+
+		return s.split(ctx, q, dims[1:], dims[1].lo, dims[1].hi)
+	}
+
+	mid := lo + (hi-lo)/2
+
+	left, err := s.split(ctx, query, dims, lo, mid)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := s.split(ctx, query, dims, mid+1, hi)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(left, right...), nil
+}
+
+func (s querySlicer) count(ctx context.Context, query string) (int, error) {
+	if err := s.limiter.Wait(ctx); err != nil {
+		return 0, err
+	}
+
+	res, _, err := s.client.Search.Repositories(ctx, query, &github.SearchOptions{
+		ListOptions: github.ListOptions{
+			PerPage: 1,
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	logrus.Debugf("Query %q has %d results", query, res.GetTotal())
+
+	return res.GetTotal(), nil
+}
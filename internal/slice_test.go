@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gaarutyunov/gh-exporter/gh"
+	"github.com/google/go-github/v45/github"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type sliceRepo struct {
+	size    int64
+	created time.Time
+}
+
+// newCountServer starts a search stand-in counting repos matching size and created ranges of the query.
+func newCountServer(t *testing.T, repos []sliceRepo) querySlicer {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/rate_limit" {
+			rate := github.Rate{Limit: 100000, Remaining: 100000, Reset: github.Timestamp{Time: time.Now().Add(time.Minute)}}
+
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"resources": map[string]any{"core": rate, "search": rate, "graphql": rate},
+			})
+
+			return
+		}
+
+		total := 0
+
+		for _, repo := range repos {
+			if matchesRanges(t, r.URL.Query().Get("q"), repo) {
+				total++
+			}
+		}
+
+		_ = json.NewEncoder(w).Encode(github.RepositoriesSearchResult{Total: github.Int(total)})
+	}))
+	t.Cleanup(srv.Close)
+
+	baseURL := gh.APIBaseURL
+	gh.APIBaseURL = srv.URL + "/"
+	t.Cleanup(func() { gh.APIBaseURL = baseURL })
+
+	client := gh.NewClient(context.Background())
+
+	return querySlicer{client: client, limiter: gh.NewLimiter(client, gh.WithLimit(gh.SearchLimit))}
+}
+
+func matchesRanges(t *testing.T, query string, repo sliceRepo) bool {
+	for _, field := range strings.Fields(query) {
+		if value, ok := strings.CutPrefix(field, "size:"); ok {
+			var lo, hi int64
+			if _, err := fmt.Sscanf(value, "%d..%d", &lo, &hi); err != nil {
+				t.Fatal(err)
+			}
+
+			if repo.size < lo || repo.size > hi {
+				return false
+			}
+		} else if value, ok := strings.CutPrefix(field, "created:"); ok {
+			from, to, _ := strings.Cut(value, "..")
+			created := repo.created.Format(dateLayout)
+
+			if created < from || created > to {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func countSlices(t *testing.T, slices []string, repos []sliceRepo) int {
+	total := 0
+
+	for _, slice := range slices {
+		n := 0
+
+		for _, repo := range repos {
+			if matchesRanges(t, slice, repo) {
+				n++
+			}
+		}
+
+		assert.Less(t, n, searchCap, slice)
+
+		total += n
+	}
+
+	return total
+}
+
+func TestQuerySlicer_Slice(t *testing.T) {
+	var repos []sliceRepo
+	for i := range 2500 {
+		repos = append(repos, sliceRepo{size: int64(i)})
+	}
+
+	slicer := newCountServer(t, repos)
+
+	slices, err := slicer.Slice(context.Background(), "language:Python", []string{"size"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Greater(t, len(slices), 2)
+
+	for _, slice := range slices {
+		assert.True(t, strings.HasPrefix(slice, "language:Python size:"), slice)
+	}
+
+	// every repository is in exactly one slice
+	assert.Equal(t, len(repos), countSlices(t, slices, repos))
+}
+
+func TestQuerySlicer_Cap(t *testing.T) {
+	for n, expected := range map[int]int{searchCap - 1: 1, searchCap: 2} {
+		var repos []sliceRepo
+		for i := range n {
+			repos = append(repos, sliceRepo{size: int64(i)})
+		}
+
+		slicer := newCountServer(t, repos)
+
+		slices, err := slicer.Slice(context.Background(), "language:Python", []string{"size"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Len(t, slices, expected, n)
+		assert.Equal(t, n, countSlices(t, slices, repos))
+	}
+}
+
+func TestQuerySlicer_NextDimension(t *testing.T) {
+	var repos []sliceRepo
+	for i := range 1500 {
+		repos = append(repos, sliceRepo{size: 7, created: firstRepoDate.Add(time.Duration(i) * day)})
+	}
+
+	slicer := newCountServer(t, repos)
+
+	// a single size holds more than the cap and can't be split further
+	slices, err := slicer.Slice(context.Background(), "language:Python", []string{"size"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{"language:Python size:7..7"}, slices)
+
+	slices, err = slicer.Slice(context.Background(), "language:Python", []string{"size", "created"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Greater(t, len(slices), 1)
+
+	for _, slice := range slices {
+		assert.True(t, strings.HasPrefix(slice, "language:Python size:7..7 created:"), slice)
+	}
+
+	assert.Equal(t, len(repos), countSlices(t, slices, repos))
+
+	_, err = slicer.Slice(context.Background(), "language:Python", []string{"stars"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// exportSummary counts the outcome of an export run.
+type exportSummary struct {
+	cloned  atomic.Int64
+	skipped atomic.Int64
+	failed  atomic.Int64
+	bytes   atomic.Int64
+	start   time.Time
+}
+
+func newExportSummary() *exportSummary {
+	return &exportSummary{start: time.Now()}
+}
+
+func (s *exportSummary) String() string {
+	return fmt.Sprintf(
+		"%d cloned, %d skipped as already exported, %d failed, %s written in %s",
+		s.cloned.Load(),
+		s.skipped.Load(),
+		s.failed.Load(),
+		formatBytes(uint64(s.bytes.Load())),
+		time.Since(s.start).Round(time.Second),
+	)
+}
+
+// failThreshold is the number or the percentage of failed repositories an export tolerates.
+type failThreshold struct {
+	value     float64
+	isPercent bool
+}
+
+func parseFailThreshold(s string) (t failThreshold, err error) {
+	value, isPercent := strings.CutSuffix(strings.TrimSpace(s), "%")
+
+	if t.value, err = strconv.ParseFloat(value, 64); err != nil || t.value < 0 {
+		return t, fmt.Errorf("invalid fail threshold, expected a count or a percentage: %s", s)
+	}
+
+	t.isPercent = isPercent
+
+	return
+}
+
+// exceeded tells whether more than the threshold of attempted repositories failed.
+func (t failThreshold) exceeded(failed, attempted int64) bool {
+	if !t.isPercent {
+		return float64(failed) > t.value
+	}
+
+	return attempted > 0 && float64(failed)*100 > t.value*float64(attempted)
+}
+
+func (t failThreshold) String() string {
+	if t.isPercent {
+		return strconv.FormatFloat(t.value, 'f', -1, 64) + "%"
+	}
+
+	return strconv.FormatFloat(t.value, 'f', -1, 64)
+}
@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseFailThreshold(t *testing.T) {
+	for s, expected := range map[string]failThreshold{
+		"10":     {value: 10},
+		"5%":     {value: 5, isPercent: true},
+		" 2.5% ": {value: 2.5, isPercent: true},
+		"0":      {value: 0},
+		"0%":     {value: 0, isPercent: true},
+	} {
+		threshold, err := parseFailThreshold(s)
+		if assert.NoError(t, err, s) {
+			assert.Equal(t, expected, threshold, s)
+		}
+	}
+
+	for _, s := range []string{"5%%", "-1", "-1%", "abc", "%", ""} {
+		_, err := parseFailThreshold(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestFailThreshold_exceeded(t *testing.T) {
+	for _, tc := range []struct {
+		threshold string
+		failed    int64
+		attempted int64
+		expected  bool
+	}{
+		{"10", 10, 100, false},
+		{"10", 11, 100, true},
+		{"0", 0, 100, false},
+		{"0", 1, 100, true},
+		{"5%", 5, 100, false},
+		{"5%", 6, 100, true},
+		{"5%", 1, 10, true},
+		{"0%", 0, 10, false},
+		{"0%", 1, 1000, true},
+		{"0%", 0, 0, false},
+		{"100%", 10, 10, false},
+	} {
+		threshold, err := parseFailThreshold(tc.threshold)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tc.expected, threshold.exceeded(tc.failed, tc.attempted), "%s of %d/%d", tc.threshold, tc.failed, tc.attempted)
+	}
+}
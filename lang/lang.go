@@ -0,0 +1,241 @@
+package lang
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+)
+
+const (
+	Python          = "Python"
+	Go              = "Go"
+	JavaScript      = "JavaScript"
+	TypeScript      = "TypeScript"
+	Java            = "Java"
+	C               = "C"
+	CPP             = "C++"
+	Ruby            = "Ruby"
+	Rust            = "Rust"
+	Perl            = "Perl"
+	Shell           = "Shell"
+	JupyterNotebook = "Jupyter Notebook"
+)
+
+var (
+	extensions = map[string]string{
+		".py":      Python,
+		".py3":     Python,
+		".pyi":     Python,
+		".pyw":     Python,
+		".pyt":     Python,
+		".gyp":     Python,
+		".gypi":    Python,
+		".wsgi":    Python,
+		".go":      Go,
+		".js":      JavaScript,
+		".cjs":     JavaScript,
+		".mjs":     JavaScript,
+		".jsx":     JavaScript,
+		".ts":      TypeScript,
+		".cts":     TypeScript,
+		".mts":     TypeScript,
+		".tsx":     TypeScript,
+		".java":    Java,
+		".c":       C,
+		".h":       C,
+		".cc":      CPP,
+		".cpp":     CPP,
+		".cxx":     CPP,
+		".hh":      CPP,
+		".hpp":     CPP,
+		".hxx":     CPP,
+		".rb":      Ruby,
+		".rake":    Ruby,
+		".gemspec": Ruby,
+		".rs":      Rust,
+		".pl":      Perl,
+		".pm":      Perl,
+		".sh":      Shell,
+		".bash":    Shell,
+		".zsh":     Shell,
+		".ipynb":   JupyterNotebook,
+	}
+	filenames = map[string]string{
+		"SConstruct":    Python,
+		"SConscript":    Python,
+		"wscript":       Python,
+		".gclient":      Python,
+		"Jakefile":      JavaScript,
+		"Rakefile":      Ruby,
+		"Gemfile":       Ruby,
+		"Vagrantfile":   Ruby,
+		".bashrc":       Shell,
+		".bash_profile": Shell,
+		".zshrc":        Shell,
+		".profile":      Shell,
+	}
+	// interpreters are named without version suffixes, e.g. python3.11 is python.
+	interpreters = map[string]string{
+		"python":  Python,
+		"pypy":    Python,
+		"node":    JavaScript,
+		"nodejs":  JavaScript,
+		"deno":    TypeScript,
+		"ts-node": TypeScript,
+		"ruby":    Ruby,
+		"jruby":   Ruby,
+		"perl":    Perl,
+		"sh":      Shell,
+		"bash":    Shell,
+		"zsh":     Shell,
+		"dash":    Shell,
+		"ksh":     Shell,
+		"ash":     Shell,
+	}
+	aliases = map[string]string{
+		"golang":  Go,
+		"js":      JavaScript,
+		"ts":      TypeScript,
+		"cpp":     CPP,
+		"bash":    Shell,
+		"sh":      Shell,
+		"jupyter": JupyterNotebook,
+	}
+)
+
+// Names returns the detected languages.
+func Names() []string {
+	var names []string
+
+	for _, name := range extensions {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// Parse returns the canonical names of the languages, matched case-insensitively or by an alias.
+func Parse(names []string) ([]string, error) {
+	res := make([]string, 0, len(names))
+
+	for _, name := range names {
+		language, ok := lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown language %q, expected one of: %s", name, strings.Join(Names(), ", "))
+		}
+
+		res = append(res, language)
+	}
+
+	return res, nil
+}
+
+func lookup(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	if language, ok := aliases[name]; ok {
+		return language, true
+	}
+
+	for _, language := range Names() {
+		if strings.ToLower(language) == name {
+			return language, true
+		}
+	}
+
+	return "", false
+}
+
+// Detect classifies the file by its name, its shebang and its extension, in that order, as linguist does.
+// Jupyter notebooks are classified by the language of their kernel.
+// It returns an empty string if the language is unknown.
+func Detect(filePath string, r io.Reader) (string, error) {
+	name := path.Base(filePath)
+
+	if language, ok := filenames[name]; ok {
+		return language, nil
+	}
+
+	language := extensions[strings.ToLower(path.Ext(name))]
+
+	if language == JupyterNotebook {
+		return notebookLanguage(r), nil
+	}
+
+	br := bufio.NewReader(r)
+
+	line, err := br.ReadSlice('\n')
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return "", err
+	}
+
+	if language, ok := interpreters[interpreter(line)]; ok {
+		return language, nil
+	}
+
+	return language, nil
+}
+
+// interpreter returns the interpreter of the shebang line without version suffix.
+func interpreter(line []byte) string {
+	line, ok := bytes.CutPrefix(line, []byte("#!"))
+	if !ok {
+		return ""
+	}
+
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return ""
+	}
+
+	name := path.Base(fields[0])
+
+	// e.g. #!/usr/bin/env -S python3 -u
+	if name == "env" {
+		name = ""
+
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+				name = path.Base(field)
+				break
+			}
+		}
+	}
+
+	return strings.TrimRight(name, "0123456789.")
+}
+
+// notebookLanguage returns the kernel language recorded in the notebook metadata.
+func notebookLanguage(r io.Reader) string {
+	var notebook struct {
+		Metadata struct {
+			Kernelspec struct {
+				Language string `json:"language"`
+			} `json:"kernelspec"`
+			LanguageInfo struct {
+				Name string `json:"name"`
+			} `json:"language_info"`
+		} `json:"metadata"`
+	}
+
+	if err := json.NewDecoder(r).Decode(&notebook); err != nil {
+		return JupyterNotebook
+	}
+
+	for _, name := range []string{notebook.Metadata.LanguageInfo.Name, notebook.Metadata.Kernelspec.Language} {
+		if language, ok := lookup(name); ok && language != JupyterNotebook {
+			return language
+		}
+	}
+
+	return JupyterNotebook
+}
@@ -0,0 +1,49 @@
+package lang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	for _, tc := range []struct {
+		path     string
+		content  string
+		language string
+	}{
+		{"pkg/a.py", "import os\n", Python},
+		{"gui.pyw", "import tkinter\n", Python},
+		{"bin/manage", "#!/usr/bin/env python3\nimport sys\n", Python},
+		{"bin/run", "#!/usr/bin/env -S python3.11 -u\n", Python},
+		{"bin/tool", "#!/usr/local/bin/pypy3\n", Python},
+		{"install.py", "#!/bin/bash\necho\n", Shell},
+		{"SConstruct", "env = Environment()\n", Python},
+		{"main.go", "package main\n", Go},
+		{"README", "Read me\n", ""},
+		{"bin/empty", "", ""},
+		{"notebook.ipynb", `{"cells": [], "metadata": {"kernelspec": {"language": "python", "name": "python3"}}}`, Python},
+		{"notebook.ipynb", `{"cells": [], "metadata": {"language_info": {"name": "go"}}}`, Go},
+		{"notebook.ipynb", `{"cells": [], "metadata": {"language_info": {"name": "julia"}}}`, JupyterNotebook},
+		{"notebook.ipynb", `not a notebook`, JupyterNotebook},
+	} {
+		language, err := Detect(tc.path, strings.NewReader(tc.content))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tc.language, language, tc.path)
+	}
+}
+
+func TestParse(t *testing.T) {
+	languages, err := Parse([]string{"python", " Go", "golang", "Jupyter Notebook", "c++"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{Python, Go, Go, JupyterNotebook, CPP}, languages)
+
+	_, err = Parse([]string{"Cobol"})
+	assert.Error(t, err)
+}
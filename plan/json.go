@@ -0,0 +1,174 @@
+package plan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/gaarutyunov/gh-exporter/gh"
+	"strings"
+)
+
+// FormatVersion is the version of the JSON plan format.
+// Version 1 is the legacy text format with blank lines between groups and a `---` remainder marker.
+const FormatVersion = 2
+
+const checksumPrefix = "sha256:"
+
+type fileJSON struct {
+	Version     int       `json:"version"`
+	Checksum    string    `json:"checksum,omitempty"`
+	Source      string    `json:"source,omitempty"`
+	Strategy    string    `json:"strategy,omitempty"`
+	Capacity    uint64    `json:"capacity,omitempty"`
+	MaxRepos    int       `json:"max_repos,omitempty"`
+	MaxPerOwner int       `json:"max_per_owner,omitempty"`
+	Count       int       `json:"count"`
+	Size        uint64    `json:"size"`
+	Bins        []binJSON `json:"bins"`
+	Remainder   *binJSON  `json:"remainder,omitempty"`
+}
+
+type binJSON struct {
+	ID     string        `json:"id"`
+	Policy Policy        `json:"policy,omitempty"`
+	Count  int           `json:"count"`
+	Size   uint64        `json:"size"`
+	Repos  []gh.RepoInfo `json:"repos"`
+}
+
+func newBinJSON(id string, policy Policy, repos []gh.RepoInfo) binJSON {
+	if repos == nil {
+		repos = []gh.RepoInfo{}
+	}
+
+	return binJSON{
+		ID:     id,
+		Policy: policy,
+		Count:  len(repos),
+		Size:   Size(repos),
+		Repos:  repos,
+	}
+}
+
+func (f File) toJSON() fileJSON {
+	doc := fileJSON{
+		Version:     FormatVersion,
+		Source:      f.Source,
+		Strategy:    f.Strategy,
+		Capacity:    f.Capacity,
+		MaxRepos:    f.MaxRepos,
+		MaxPerOwner: f.MaxPerOwner,
+		Count:       f.Total(false, false),
+		Bins:        make([]binJSON, 0, len(f.Bins)),
+	}
+
+	for i, bin := range f.Bins {
+		b := newBinJSON(BinID(i), "", bin)
+		doc.Size += b.Size
+		doc.Bins = append(doc.Bins, b)
+	}
+
+	if len(f.Remainder) > 0 {
+		b := newBinJSON(RemainderID, f.Policy, f.Remainder)
+		doc.Size += b.Size
+		doc.Remainder = &b
+	}
+
+	return doc
+}
+
+// checksum is the SHA-256 of the document encoded without the checksum.
+func (doc fileJSON) checksum() (string, error) {
+	doc.Checksum = ""
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return checksumPrefix + hex.EncodeToString(sum[:]), nil
+}
+
+func (f File) MarshalJSON() ([]byte, error) {
+	doc := f.toJSON()
+
+	var err error
+	if doc.Checksum, err = doc.checksum(); err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func (f *File) UnmarshalJSON(data []byte) error {
+	var doc fileJSON
+
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("invalid plan: %w", err)
+	}
+
+	if doc.Version != FormatVersion {
+		return fmt.Errorf("unsupported plan format version %d", doc.Version)
+	}
+
+	if doc.Checksum != "" {
+		if !strings.HasPrefix(doc.Checksum, checksumPrefix) {
+			return fmt.Errorf("unsupported plan checksum: %s", doc.Checksum)
+		}
+
+		expected, err := doc.checksum()
+		if err != nil {
+			return err
+		}
+
+		if expected != doc.Checksum {
+			return fmt.Errorf("plan checksum mismatch: expected %s, got %s", expected, doc.Checksum)
+		}
+	}
+
+	*f = File{
+		Bins:        make([][]gh.RepoInfo, 0, len(doc.Bins)),
+		Remainder:   []gh.RepoInfo{},
+		Policy:      PolicyKeep,
+		Source:      doc.Source,
+		Strategy:    doc.Strategy,
+		Capacity:    doc.Capacity,
+		MaxRepos:    doc.MaxRepos,
+		MaxPerOwner: doc.MaxPerOwner,
+	}
+
+	// groups are named by the position of bins, so bins can't be renamed, reordered or emptied
+	for i, bin := range doc.Bins {
+		if bin.ID != BinID(i) {
+			return fmt.Errorf("invalid plan: bin %d has ID %q, expected %q", i, bin.ID, BinID(i))
+		}
+
+		if len(bin.Repos) == 0 {
+			return fmt.Errorf("invalid plan: bin %s is empty", bin.ID)
+		}
+
+		f.Bins = append(f.Bins, bin.Repos)
+	}
+
+	if doc.Remainder != nil {
+		if doc.Remainder.ID != RemainderID {
+			return fmt.Errorf("invalid plan: remainder has ID %q, expected %q", doc.Remainder.ID, RemainderID)
+		}
+
+		f.Remainder = doc.Remainder.Repos
+
+		if doc.Remainder.Policy != "" {
+			policy, err := ParsePolicy(string(doc.Remainder.Policy))
+			if err != nil {
+				return err
+			}
+
+			f.Policy = policy
+		}
+	}
+
+	return nil
+}
@@ -2,19 +2,98 @@ package plan
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"github.com/gaarutyunov/gh-exporter/gh"
 	"iter"
 	"os"
+	"strconv"
 	"strings"
 )
 
+// Policy describes how repositories larger than the group capacity are planned.
+type Policy string
+
+const (
+	// PolicyKeep puts all oversized repositories into a single remainder group.
+	PolicyKeep Policy = "keep"
+	// PolicyDedicated puts every oversized repository into its own group.
+	PolicyDedicated Policy = "dedicated"
+	// PolicyHuge puts oversized repositories into a separate tier exported with its own concurrency.
+	PolicyHuge Policy = "huge"
+	// PolicyExclude leaves oversized repositories out of the plan.
+	PolicyExclude Policy = "exclude"
+)
+
+const remainderMarker = "---"
+
+// RemainderID is the ID of the remainder group.
+const RemainderID = "remainder"
+
+// BinID returns the ID of the i-th packed bin.
+func BinID(i int) string {
+	return fmt.Sprintf("bin-%04d", i)
+}
+
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case PolicyKeep, PolicyDedicated, PolicyHuge, PolicyExclude:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown remainder policy: %s", s)
+	}
+}
+
+// Group is a set of repositories exported together.
+type Group struct {
+	ID    string
+	Repos []gh.RepoInfo
+	// Policy is the remainder policy that produced the group, empty for packed bins.
+	Policy Policy
+}
+
+func (g Group) IsRemainder() bool {
+	return g.Policy != ""
+}
+
 type File struct {
 	Bins      [][]gh.RepoInfo
 	Remainder []gh.RepoInfo
+	// Policy is the remainder policy, PolicyKeep if not set.
+	Policy Policy
+	// Source is the results file the plan was made from.
+	Source string
+	// Strategy is the name of the bin packing strategy.
+	Strategy string
+	// Capacity is the maximum size of a bin in kilobytes.
+	Capacity    uint64
+	MaxRepos    int
+	MaxPerOwner int
 }
 
 func New(bins [][]gh.RepoInfo, remainder []gh.RepoInfo) File {
-	return File{Bins: bins, Remainder: remainder}
+	return File{Bins: bins, Remainder: remainder, Policy: PolicyKeep}
+}
+
+// WithPolicy returns the plan with the remainder planned according to the policy.
+func (f File) WithPolicy(policy Policy) File {
+	f.Policy = policy
+
+	if policy == PolicyExclude {
+		f.Remainder = nil
+	}
+
+	return f
+}
+
+// Size returns the total size of repositories in kilobytes.
+func Size(repos []gh.RepoInfo) (n uint64) {
+	for _, repo := range repos {
+		n += repo.Size()
+	}
+
+	return
 }
 
 func (f File) Total(skipRemainder, onlyRemainder bool) (n int) {
@@ -35,30 +114,85 @@ func (f File) Total(skipRemainder, onlyRemainder bool) (n int) {
 	return
 }
 
+// Group returns the group with the given ID, a bin can also be referred to by its index.
+func (f File) Group(id string) (Group, bool) {
+	if i, err := strconv.Atoi(id); err == nil {
+		id = BinID(i)
+	}
+
+	for group := range f.Iter(false, false) {
+		if group.ID == id {
+			return group, true
+		}
+	}
+
+	return Group{}, false
+}
+
+// Open reads a plan in the JSON format or in the legacy text format.
 func Open(path string) (file File, err error) {
-	fi, err := os.Open(path)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return
 	}
-	defer fi.Close()
 
-	scanner := bufio.NewScanner(fi)
+	if isJSON(data) {
+		err = json.Unmarshal(data, &file)
+		return
+	}
+
+	return parseText(data)
+}
+
+// isJSON tells a JSON plan from the legacy format, whose lines can be JSON results lines too.
+func isJSON(data []byte) bool {
+	if !json.Valid(data) {
+		return false
+	}
+
+	var probe struct {
+		Version int `json:"version"`
+	}
+
+	return json.Unmarshal(data, &probe) == nil && probe.Version != 0
+}
+
+// parseText reads the legacy format with groups separated by blank lines followed by the remainder marker.
+func parseText(data []byte) (file File, err error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
 
 	file.Bins = [][]gh.RepoInfo{}
 	file.Remainder = []gh.RepoInfo{}
+	file.Policy = PolicyKeep
 
 	var isRemainder bool
 	var group []gh.RepoInfo
 
-	for scanner.Scan() {
-		if scanner.Text() == "" {
+	closeGroup := func() {
+		if !isRemainder && len(group) > 0 {
 			file.Bins = append(file.Bins, group)
+		}
 
-			group = []gh.RepoInfo{}
-		} else if scanner.Text() == "---" {
+		group = nil
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" {
+			closeGroup()
+		} else if marker, ok := strings.CutPrefix(line, remainderMarker); ok {
+			closeGroup()
 			isRemainder = true
+
+			if marker = strings.TrimSpace(marker); marker != "" {
+				if file.Policy, err = ParsePolicy(marker); err != nil {
+					return
+				}
+			}
 		} else {
-			repo, err := gh.RepoInfoFromString(scanner.Text())
+			repo, err := gh.RepoInfoFromString(line)
 			if err != nil {
 				return file, err
 			}
@@ -71,53 +205,55 @@ func Open(path string) (file File, err error) {
 		}
 	}
 
-	if scanner.Err() != nil {
-		err = scanner.Err()
-	}
+	closeGroup()
+
+	err = scanner.Err()
 
 	return
 }
 
-func (f File) Iter(skipRemainder, onlyRemainder bool) iter.Seq2[[]gh.RepoInfo, bool] {
-	return func(yield func([]gh.RepoInfo, bool) bool) {
+// Iter yields packed bins followed by remainder groups according to the remainder policy.
+func (f File) Iter(skipRemainder, onlyRemainder bool) iter.Seq[Group] {
+	return func(yield func(Group) bool) {
 		if !onlyRemainder {
-			for _, bin := range f.Bins {
-				if !yield(bin, false) {
+			for i, bin := range f.Bins {
+				if !yield(Group{ID: BinID(i), Repos: bin}) {
 					return
 				}
 			}
 		}
 
-		if skipRemainder {
+		if skipRemainder || len(f.Remainder) == 0 {
 			return
 		}
 
-		if !yield(f.Remainder, true) {
-			return
-		}
-	}
-}
+		switch f.Policy {
+		case PolicyDedicated:
+			for i, repo := range f.Remainder {
+				group := Group{ID: fmt.Sprintf("%s-%04d", RemainderID, i), Repos: []gh.RepoInfo{repo}, Policy: f.Policy}
 
-func (f File) String() string {
-	var builder strings.Builder
+				if !yield(group) {
+					return
+				}
+			}
+		case PolicyExclude:
+		default:
+			policy := f.Policy
+			if policy == "" {
+				policy = PolicyKeep
+			}
 
-	for _, bin := range f.Bins {
-		for _, repo := range bin {
-			builder.WriteString(repo.String())
-			builder.WriteString("\n")
+			yield(Group{ID: RemainderID, Repos: f.Remainder, Policy: policy})
 		}
-
-		builder.WriteString("\n")
-	}
-
-	if len(f.Remainder) > 0 {
-		builder.WriteString("---\n")
 	}
+}
 
-	for _, repo := range f.Remainder {
-		builder.WriteString(repo.String())
-		builder.WriteString("\n")
+// String returns the plan in the JSON format.
+func (f File) String() string {
+	data, err := f.MarshalJSON()
+	if err != nil {
+		return fmt.Sprintf("invalid plan: %v", err)
 	}
 
-	return builder.String()
+	return string(data)
 }
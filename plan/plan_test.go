@@ -0,0 +1,110 @@
+package plan
+
+import (
+	"encoding/json"
+	"github.com/gaarutyunov/gh-exporter/gh"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testFile() File {
+	f := New(
+		[][]gh.RepoInfo{
+			{gh.NewRepoInfo("a/a", "git@github.com:a/a.git", 10), gh.NewRepoInfo("a/b", "git@github.com:a/b.git", 20)},
+			{gh.NewRepoInfo("b/a", "git@github.com:b/a.git", 30).WithSHA("abc")},
+		},
+		[]gh.RepoInfo{gh.NewRepoInfo("c/a", "git@github.com:c/a.git", 100)},
+	).WithPolicy(PolicyDedicated)
+
+	f.Source = "results.jsonl"
+	f.Strategy = "first-fit"
+	f.Capacity = 50
+	f.MaxPerOwner = 2
+
+	return f
+}
+
+func writeFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "plan")
+
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestOpen_JSON(t *testing.T) {
+	expected := testFile()
+
+	actual, err := Open(writeFile(t, expected.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, expected, actual)
+
+	var ids []string
+	for group := range actual.Iter(false, false) {
+		ids = append(ids, group.ID)
+	}
+
+	assert.Equal(t, []string{"bin-0000", "bin-0001", "remainder-0000"}, ids)
+}
+
+func TestOpen_Checksum(t *testing.T) {
+	content := strings.Replace(testFile().String(), `"size": 30`, `"size": 31`, 1)
+
+	_, err := Open(writeFile(t, content))
+
+	assert.ErrorContains(t, err, "checksum mismatch")
+}
+
+func TestOpen_BinIDs(t *testing.T) {
+	for name, edit := range map[string]func(doc *fileJSON){
+		"renamed":   func(doc *fileJSON) { doc.Bins[0].ID = "bin-0003" },
+		"reordered": func(doc *fileJSON) { doc.Bins[0], doc.Bins[1] = doc.Bins[1], doc.Bins[0] },
+		"removed":   func(doc *fileJSON) { doc.Bins = doc.Bins[1:] },
+		"emptied":   func(doc *fileJSON) { doc.Bins[0].Repos = []gh.RepoInfo{} },
+		"remainder": func(doc *fileJSON) { doc.Remainder.ID = "bin-0002" },
+	} {
+		// hand edited plans have no checksum
+		doc := testFile().toJSON()
+		edit(&doc)
+
+		data, err := json.Marshal(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = Open(writeFile(t, string(data)))
+
+		assert.ErrorContains(t, err, "invalid plan", name)
+	}
+}
+
+func TestOpen_Text(t *testing.T) {
+	content := strings.Join([]string{
+		"a/a;git@github.com:a/a.git;10;",
+		"a/b;git@github.com:a/b.git;20;",
+		"",
+		"",
+		"b/a;git@github.com:b/a.git;30;abc",
+		"--- huge",
+		"c/a;git@github.com:c/a.git;100;",
+		"",
+	}, "\n")
+
+	f, err := Open(writeFile(t, content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, f.Bins, 2)
+	assert.Equal(t, 3, f.Total(true, false))
+	assert.Equal(t, PolicyHuge, f.Policy)
+	assert.Len(t, f.Remainder, 1)
+}
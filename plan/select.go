@@ -0,0 +1,121 @@
+package plan
+
+import (
+	"fmt"
+	"iter"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Selector selects groups of a plan.
+type Selector func(seq iter.Seq[Group]) iter.Seq[Group]
+
+// ParseBins returns a selector of groups by a comma separated list of bin indices, ranges of indices and group IDs,
+// e.g. 3,7-12,remainder.
+func ParseBins(s string) (Selector, error) {
+	ids := make(map[string]struct{})
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		from, to, isRange := strings.Cut(part, "-")
+
+		start, err := strconv.Atoi(from)
+		if err != nil {
+			if !unicode.IsLetter(rune(part[0])) {
+				return nil, fmt.Errorf("invalid bin range: %s", part)
+			}
+
+			// not an index, e.g. bin-0003 or remainder
+			ids[part] = struct{}{}
+			continue
+		}
+
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(to); err != nil {
+				return nil, fmt.Errorf("invalid bin range: %s", part)
+			}
+		}
+
+		if start < 0 || end < start {
+			return nil, fmt.Errorf("invalid bin range: %s", part)
+		}
+
+		for i := start; i <= end; i++ {
+			ids[BinID(i)] = struct{}{}
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no bins selected: %s", s)
+	}
+
+	return func(seq iter.Seq[Group]) iter.Seq[Group] {
+		return func(yield func(Group) bool) {
+			for group := range seq {
+				if _, ok := ids[group.ID]; !ok {
+					continue
+				}
+
+				if !yield(group) {
+					return
+				}
+			}
+		}
+	}, nil
+}
+
+// ParseShard returns a selector of the i-th of n shards in the i/n format with i starting from 0.
+// Groups are assigned to shards in turn, so n shards with the same plan cover it exactly once.
+func ParseShard(s string) (Selector, error) {
+	index, count, ok := strings.Cut(s, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid shard, expected i/n: %s", s)
+	}
+
+	i, err := strconv.Atoi(strings.TrimSpace(index))
+	if err != nil {
+		return nil, fmt.Errorf("invalid shard index: %s", s)
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil {
+		return nil, fmt.Errorf("invalid shard count: %s", s)
+	}
+
+	if n < 1 || i < 0 || i >= n {
+		return nil, fmt.Errorf("shard index must be in [0, %d): %s", n, s)
+	}
+
+	return func(seq iter.Seq[Group]) iter.Seq[Group] {
+		return func(yield func(Group) bool) {
+			k := 0
+
+			for group := range seq {
+				k++
+
+				if (k-1)%n != i {
+					continue
+				}
+
+				if !yield(group) {
+					return
+				}
+			}
+		}
+	}, nil
+}
+
+// Count returns the number of repositories in the groups.
+func Count(seq iter.Seq[Group]) (n int) {
+	for group := range seq {
+		n += len(group.Repos)
+	}
+
+	return
+}
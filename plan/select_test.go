@@ -0,0 +1,80 @@
+package plan
+
+import (
+	"fmt"
+	"github.com/gaarutyunov/gh-exporter/gh"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func shardFile() File {
+	bins := make([][]gh.RepoInfo, 13)
+
+	for i := range bins {
+		name := fmt.Sprintf("owner/repo-%d", i)
+		bins[i] = []gh.RepoInfo{gh.NewRepoInfo(name, "git@github.com:"+name+".git", 10)}
+	}
+
+	return New(bins, []gh.RepoInfo{
+		gh.NewRepoInfo("big/a", "git@github.com:big/a.git", 1000),
+		gh.NewRepoInfo("big/b", "git@github.com:big/b.git", 1000),
+	}).WithPolicy(PolicyDedicated)
+}
+
+func ids(f File, selectors ...Selector) (ids []string) {
+	groups := f.Iter(false, false)
+
+	for _, selector := range selectors {
+		groups = selector(groups)
+	}
+
+	for group := range groups {
+		ids = append(ids, group.ID)
+	}
+
+	return
+}
+
+func TestParseBins(t *testing.T) {
+	selector, err := ParseBins("3,7-9, remainder-0001,12-12")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{"bin-0003", "bin-0007", "bin-0008", "bin-0009", "bin-0012", "remainder-0001"}, ids(shardFile(), selector))
+
+	for _, s := range []string{"", "9-7", "3-x", "-1"} {
+		_, err := ParseBins(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestParseShard(t *testing.T) {
+	f := shardFile()
+	n := 4
+	seen := make(map[string]int)
+
+	for i := 0; i < n; i++ {
+		selector, err := ParseShard(fmt.Sprintf("%d/%d", i, n))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, id := range ids(f, selector) {
+			seen[id]++
+		}
+	}
+
+	all := ids(f)
+
+	assert.Len(t, seen, len(all))
+
+	for _, id := range all {
+		assert.Equal(t, 1, seen[id], id)
+	}
+
+	for _, s := range []string{"4/4", "-1/4", "0/0", "1", "a/b"} {
+		_, err := ParseShard(s)
+		assert.Error(t, err, s)
+	}
+}
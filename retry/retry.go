@@ -0,0 +1,63 @@
+package retry
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Policy retries an operation with exponential backoff and full jitter.
+type Policy struct {
+	// Attempts is the maximum number of attempts, values below 1 mean a single attempt.
+	Attempts int
+	// Initial is the backoff before the second attempt, doubled after every attempt.
+	Initial time.Duration
+	// Max caps the backoff, 0 means no cap.
+	Max time.Duration
+}
+
+// Backoff returns the maximum delay after the given failed attempt starting from 1.
+func (p Policy) Backoff(attempt int) time.Duration {
+	d := p.Initial
+
+	for i := 1; i < attempt; i++ {
+		if p.Max > 0 && d >= p.Max {
+			break
+		}
+
+		d *= 2
+	}
+
+	if p.Max > 0 && d > p.Max {
+		d = p.Max
+	}
+
+	return d
+}
+
+// Do calls fn until it succeeds, returns an error that is not retryable or the attempts are exhausted.
+// Between attempts it sleeps a random duration up to the backoff. It returns the number of attempts made and the last error.
+func (p Policy) Do(ctx context.Context, retryable func(error) bool, fn func(attempt int) error) (attempt int, err error) {
+	for attempt = 1; ; attempt++ {
+		if err = fn(attempt); err == nil || attempt >= p.Attempts || !retryable(err) {
+			return
+		}
+
+		timer := time.NewTimer(jitter(p.Backoff(attempt)))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+
+	return rand.N(d + 1)
+}
@@ -0,0 +1,69 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var (
+	errTransient = errors.New("connection reset")
+	errFatal     = errors.New("repository not found")
+)
+
+func isTransient(err error) bool {
+	return errors.Is(err, errTransient)
+}
+
+func TestPolicy_Backoff(t *testing.T) {
+	p := Policy{Attempts: 10, Initial: time.Second, Max: 5 * time.Second}
+
+	assert.Equal(t, time.Second, p.Backoff(1))
+	assert.Equal(t, 2*time.Second, p.Backoff(2))
+	assert.Equal(t, 4*time.Second, p.Backoff(3))
+	assert.Equal(t, 5*time.Second, p.Backoff(4))
+	assert.Equal(t, 5*time.Second, p.Backoff(100))
+}
+
+func TestPolicy_Do(t *testing.T) {
+	p := Policy{Attempts: 3, Initial: time.Millisecond}
+
+	tests := []struct {
+		name     string
+		errs     []error
+		attempts int
+		err      error
+	}{
+		{"success", []error{nil}, 1, nil},
+		{"transient then success", []error{errTransient, nil}, 2, nil},
+		{"exhausted", []error{errTransient, errTransient, errTransient, nil}, 3, errTransient},
+		{"fatal", []error{errTransient, errFatal, nil}, 2, errFatal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts, err := p.Do(context.Background(), isTransient, func(attempt int) error {
+				return tt.errs[attempt-1]
+			})
+
+			assert.Equal(t, tt.attempts, attempts)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
+
+func TestPolicy_Do_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := Policy{Attempts: 3, Initial: time.Hour}
+
+	attempts, err := p.Do(ctx, isTransient, func(attempt int) error {
+		return errTransient
+	})
+
+	assert.Equal(t, 1, attempts)
+	assert.ErrorIs(t, err, context.Canceled)
+}